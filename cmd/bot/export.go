@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"clawclack/pkg/agent"
+)
+
+// runExport implements the export subcommand, which writes the agent's
+// transaction history without starting the bot:
+//
+//	bot export [-format csv|jsonl|journal] [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-o file]
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "csv", "csv, jsonl or journal (hledger/ledger-cli)")
+	from := flags.String("from", "", "first day to include, YYYY-MM-DD")
+	to := flags.String("to", "", "last day to include, YYYY-MM-DD")
+	output := flags.String("o", "-", "file to write, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := exportLedger(loadConfig(), *format, *from, *to, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+	return 0
+}
+
+func exportLedger(config *Config, formatName, fromDay, toDay, output string) error {
+	format, err := agent.ParseExportFormat(formatName)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(config.Agent.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid agent.time_zone: %w", err)
+	}
+	from, to, err := agent.DayRange(fromDay, toDay, location)
+	if err != nil {
+		return err
+	}
+
+	// Replaying through an agent collapses approval updates into one entry.
+	// The bot may be appending to the ledger, so it is only ever read here.
+	ledger, err := agent.OpenFileLedgerReadOnly(filepath.Join(config.DataDir, "ledger.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+	a := agent.New(agent.Config{Location: location, Ledger: ledger})
+	if err := a.Load(); err != nil {
+		return err
+	}
+
+	txs := a.Transactions(from, to)
+	if output == "-" {
+		return agent.Export(os.Stdout, txs, format, location)
+	}
+
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	if err := agent.Export(file, txs, format, location); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // budget time zones work on minimal images without zoneinfo
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/viper"
@@ -16,7 +22,15 @@ import (
 	"maunium.net/go/mautrix/id"
 
 	"clawclack/pkg/agent"
+	"clawclack/pkg/article"
+	"clawclack/pkg/credits"
+	"clawclack/pkg/fetch"
 	"clawclack/pkg/handlers"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/payees"
+	"clawclack/pkg/rates"
+	"clawclack/pkg/refunds"
 	"clawclack/pkg/shkeeper"
 )
 
@@ -25,7 +39,18 @@ type Bot struct {
 	Config    *Config
 	SHKeeper  *shkeeper.Client
 	Agent     *agent.Agent
+	Ledger    *agent.FileLedger
+	Orders    *orders.Store
+	Payments  *handlers.Payments
+	Refunds   *handlers.Refunds
+	Approvals *handlers.Approvals
+	Proposals *handlers.Proposals
+	Reports   *handlers.Reports
+	Credits   *credits.Store
+	Payees    *payees.Store
+	Fetcher   *fetch.Client // the only way user-supplied URLs are fetched
 	Handlers  *handlers.Registry
+	Webhook   *http.Server
 }
 
 type Config struct {
@@ -36,18 +61,139 @@ type Config struct {
 		DeviceID   string `mapstructure:"device_id"`
 	}
 	SHKeeper struct {
-		URL    string `mapstructure:"url"`
-		APIKey string `mapstructure:"api_key"`
+		URL         string        `mapstructure:"url"`
+		APIKey      string        `mapstructure:"api_key"`
+		CurrencyTTL time.Duration `mapstructure:"currency_ttl"`
+	}
+	Webhook struct {
+		Listen       string        `mapstructure:"listen"`
+		URL          string        `mapstructure:"url"`
+		Secret       string        `mapstructure:"secret"`
+		PollInterval time.Duration `mapstructure:"poll_interval"`
+	}
+	Rates struct {
+		URL           string        `mapstructure:"url"`
+		APIKey        string        `mapstructure:"api_key"`
+		CacheTTL      time.Duration `mapstructure:"cache_ttl"`
+		QuoteValidity time.Duration `mapstructure:"quote_validity"`
+	}
+	Payments struct {
+		Expiry     map[string]time.Duration `mapstructure:"expiry"`
+		LateWindow time.Duration            `mapstructure:"late_window"`
 	}
 	Agent struct {
-		SpendingLimitUSD float64 `mapstructure:"spending_limit_usd"`
-		DailyBudgetUSD   float64 `mapstructure:"daily_budget_usd"`
-		OpenAIKey        string  `mapstructure:"openai_key"`
+		LimitsConfig `mapstructure:",squash"`
+		Categories       map[string]LimitsConfig `mapstructure:"categories"`
+		BudgetMode       string                  `mapstructure:"budget_mode"`
+		RevenueBudget    RevenueBudgetConfig     `mapstructure:"revenue_budget"`
+		Pricing          PricingConfig           `mapstructure:"pricing"`
+		TimeZone         string `mapstructure:"time_zone"`
+		OpenAIKey        string  `mapstructure:"openai_key"` // older configs; llm.api_key wins
+	}
+	LLM struct {
+		Provider string        `mapstructure:"provider"` // openai, stub or none
+		BaseURL  string        `mapstructure:"base_url"`
+		APIKey   string        `mapstructure:"api_key"`
+		Model    string        `mapstructure:"model"`
+		Timeout  time.Duration `mapstructure:"timeout"`
+	}
+	Admin struct {
+		Users           []string      `mapstructure:"users"`
+		Room            string        `mapstructure:"room"`
+		ApprovalTimeout time.Duration `mapstructure:"approval_timeout"`
+	}
+	Refunds struct {
+		AutoApproveUSD string `mapstructure:"auto_approve_usd"`
+	}
+	Reports struct {
+		Room    string   `mapstructure:"room"`
+		Periods []string `mapstructure:"periods"`
+	}
+	Alerts struct {
+		Room       string `mapstructure:"room"`
+		Thresholds []int  `mapstructure:"thresholds"`
 	}
+	Proposals struct {
+		TTL time.Duration `mapstructure:"ttl"`
+	}
+	Fetch FetchConfig `mapstructure:"fetch"`
+	DataDir  string `mapstructure:"data_dir"`
 	LogLevel string `mapstructure:"log_level"`
 }
 
+// LimitsConfig is a set of spending limits in USD; empty values are not enforced
+type LimitsConfig struct {
+	SpendingLimitUSD string `mapstructure:"spending_limit_usd"`
+	DailyBudgetUSD   string `mapstructure:"daily_budget_usd"`
+	RollingBudgetUSD string `mapstructure:"rolling_budget_usd"`
+	WeeklyBudgetUSD  string `mapstructure:"weekly_budget_usd"`
+	MonthlyBudgetUSD string `mapstructure:"monthly_budget_usd"`
+}
+
+// parse converts the limits, naming prefix in errors
+func (c LimitsConfig) parse(prefix string) (agent.Limits, error) {
+	var limits agent.Limits
+	for _, field := range []struct {
+		key   string
+		value string
+		dst   *money.Money
+	}{
+		{"spending_limit_usd", c.SpendingLimitUSD, &limits.SpendingLimitUSD},
+		{"daily_budget_usd", c.DailyBudgetUSD, &limits.DailyBudgetUSD},
+		{"rolling_budget_usd", c.RollingBudgetUSD, &limits.RollingBudgetUSD},
+		{"weekly_budget_usd", c.WeeklyBudgetUSD, &limits.WeeklyBudgetUSD},
+		{"monthly_budget_usd", c.MonthlyBudgetUSD, &limits.MonthlyBudgetUSD},
+	} {
+		*field.dst = money.New(money.Zero, money.USD)
+		if field.value == "" {
+			continue
+		}
+		parsed, err := money.Parse(field.value, money.USD)
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s.%s: %w", prefix, field.key, err)
+		}
+		*field.dst = parsed
+	}
+	return limits, nil
+}
+
+// RevenueBudgetConfig derives the daily budget from trailing profit
+// when agent.budget_mode is "revenue"
+type RevenueBudgetConfig struct {
+	Percent    string `mapstructure:"percent"`
+	Days       int    `mapstructure:"days"`
+	FloorUSD   string `mapstructure:"floor_usd"`
+	CeilingUSD string `mapstructure:"ceiling_usd"`
+}
+
+// parse converts the revenue budget settings
+func (r RevenueBudgetConfig) parse() (*agent.DynamicBudget, error) {
+	percent, err := money.ParseDecimal(r.Percent)
+	if err != nil || percent.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid agent.revenue_budget.percent %q", r.Percent)
+	}
+	floor, err := money.Parse(r.FloorUSD, money.USD)
+	if err != nil || floor.Sign() < 0 {
+		return nil, fmt.Errorf("invalid agent.revenue_budget.floor_usd %q", r.FloorUSD)
+	}
+	ceiling, err := money.Parse(r.CeilingUSD, money.USD)
+	if err != nil || ceiling.Cmp(floor) < 0 {
+		return nil, fmt.Errorf("invalid agent.revenue_budget.ceiling_usd %q, it must be at least the floor", r.CeilingUSD)
+	}
+
+	return &agent.DynamicBudget{
+		Percent: percent,
+		Days:    r.Days,
+		Floor:   floor,
+		Ceiling: ceiling,
+	}, nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
 	log.Info("🤖 Starting ClawClack Agent...")
 
 	config := loadConfig()
@@ -71,6 +217,75 @@ func main() {
 	bot.Stop()
 }
 
+// PricingConfig bounds the prices quoted for custom services
+type PricingConfig struct {
+	MinUSD        string `mapstructure:"min_usd"`
+	MaxUSD        string `mapstructure:"max_usd"`
+	MarginPercent string `mapstructure:"margin_percent"`
+}
+
+// parse converts the pricing bounds; empty values keep the agent's defaults
+func (p PricingConfig) parse() (agent.PricingPolicy, error) {
+	var policy agent.PricingPolicy
+	var err error
+	if p.MinUSD != "" {
+		if policy.Min, err = money.Parse(p.MinUSD, money.USD); err != nil || policy.Min.Sign() < 0 {
+			return policy, fmt.Errorf("invalid agent.pricing.min_usd %q", p.MinUSD)
+		}
+	}
+	if p.MaxUSD != "" {
+		if policy.Max, err = money.Parse(p.MaxUSD, money.USD); err != nil || policy.Max.Cmp(policy.Min) < 0 {
+			return policy, fmt.Errorf("invalid agent.pricing.max_usd %q, it must be at least the minimum", p.MaxUSD)
+		}
+	}
+	if p.MarginPercent != "" {
+		if policy.Margin, err = money.ParseDecimal(p.MarginPercent); err != nil || policy.Margin.Sign() < 0 {
+			return policy, fmt.Errorf("invalid agent.pricing.margin_percent %q", p.MarginPercent)
+		}
+	}
+	return policy, nil
+}
+
+// FetchConfig limits what user-supplied URLs the bot fetches
+type FetchConfig struct {
+	MaxBytes     int64         `mapstructure:"max_bytes"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxRedirects int           `mapstructure:"max_redirects"`
+	Ports        []int         `mapstructure:"ports"`
+	Blocked      []string      `mapstructure:"blocked"`
+}
+
+// client creates the fetcher; the SHKeeper host is always blocked on top of
+// the private and reserved ranges
+func (f FetchConfig) client(shkeeperURL string) (*fetch.Client, error) {
+	var blocked []netip.Prefix
+	for _, raw := range f.Blocked {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(raw)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid fetch.blocked range %q: %w", raw, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		blocked = append(blocked, prefix)
+	}
+	if u, err := url.Parse(shkeeperURL); err == nil {
+		if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+			blocked = append(blocked, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	return fetch.New(fetch.Options{
+		Ports:        f.Ports,
+		Blocked:      blocked,
+		MaxBytes:     f.MaxBytes,
+		Timeout:      f.Timeout,
+		MaxRedirects: f.MaxRedirects,
+		UserAgent:    "ClawClackBot/1.0 (+https://clawclack.click)",
+	}), nil
+}
+
 func NewBot(config *Config) (*Bot, error) {
 	// Create Matrix client
 	client, err := mautrix.NewClient(config.Matrix.Homeserver, "", "")
@@ -84,23 +299,202 @@ func NewBot(config *Config) (*Bot, error) {
 	// Create SHKeeper client
 	skClient := shkeeper.New(config.SHKeeper.URL, config.SHKeeper.APIKey)
 
-	// Create AI agent
+	// User-supplied URLs must never reach SHKeeper or anything else internal
+	fetcher, err := config.Fetch.client(config.SHKeeper.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create AI agent; the top-level limits cap all categories together
+	limits, err := config.Agent.LimitsConfig.parse("agent")
+	if err != nil {
+		return nil, err
+	}
+	categories := make(map[string]agent.Limits)
+	for name, raw := range config.Agent.Categories {
+		if categories[name], err = raw.parse("agent.categories." + name); err != nil {
+			return nil, err
+		}
+	}
+	location, err := time.LoadLocation(config.Agent.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent.time_zone: %w", err)
+	}
+	var dynamic *agent.DynamicBudget
+	switch config.Agent.BudgetMode {
+	case "", "fixed":
+	case "revenue":
+		if dynamic, err = config.Agent.RevenueBudget.parse(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid agent.budget_mode %q, use fixed or revenue", config.Agent.BudgetMode)
+	}
+
+	// The agent only ever pays addresses admins put in the address book
+	payeeStore, err := payees.Open(filepath.Join(config.DataDir, "payees.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	llm, err := newLLM(config)
+	if err != nil {
+		return nil, err
+	}
+	pricing, err := config.Agent.Pricing.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	// Open persistent order store; paid proposals also price new ones
+	orderStore, err := orders.Open(filepath.Join(config.DataDir, "orders.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The ledger is replayed here, before any command can spend
+	ledger, err := agent.OpenFileLedger(filepath.Join(config.DataDir, "ledger.jsonl"))
+	if err != nil {
+		return nil, err
+	}
 	aiAgent := agent.New(agent.Config{
-		SpendingLimitUSD: config.Agent.SpendingLimitUSD,
-		DailyBudgetUSD:   config.Agent.DailyBudgetUSD,
-		OpenAIKey:        config.Agent.OpenAIKey,
+		Limits:     limits,
+		Categories: categories,
+		Location:   location,
+		LLM:        llm,
+		Ledger:     ledger,
+		Payees:     payeeStore,
+		// In revenue mode the daily budget follows trailing profit
+		DynamicBudget: dynamic,
+		Pricing:       pricing,
+		History:       &handlers.ProposalHistory{Orders: orderStore},
 	})
+	if err := aiAgent.Load(); err != nil {
+		return nil, err
+	}
+
+	// Open refund queue
+	refundStore, err := refunds.Open(filepath.Join(config.DataDir, "refunds.db"))
+	if err != nil {
+		return nil, err
+	}
+	autoApprove, err := money.Parse(config.Refunds.AutoApproveUSD, money.USD)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refunds.auto_approve_usd: %w", err)
+	}
+
+	// Open prepaid credit accounts
+	creditStore, err := credits.Open(filepath.Join(config.DataDir, "credits.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	refunder := &handlers.Refunds{
+		Client:      client,
+		SHKeeper:    skClient,
+		Agent:       aiAgent,
+		Store:       refundStore,
+		AdminRoom:   id.RoomID(config.Admin.Room),
+		AutoApprove: autoApprove,
+	}
 
 	bot := &Bot{
 		Client:   client,
 		Config:   config,
 		SHKeeper: skClient,
 		Agent:    aiAgent,
+		Ledger:   ledger,
+		Orders:   orderStore,
+		Refunds:  refunder,
+		Credits:  creditStore,
+		Payees:   payeeStore,
+		Fetcher:  fetcher,
+		// Open !propose quotes are kept in memory only
+		Proposals: &handlers.Proposals{TTL: config.Proposals.TTL},
+		Payments: &handlers.Payments{
+			Client:   client,
+			SHKeeper: skClient,
+			Agent:    aiAgent,
+			Orders:   orderStore,
+			Refunds:  refunder,
+			Credits:  creditStore,
+			// Enabled wallets are discovered from SHKeeper rather than hardcoded
+			Currencies: shkeeper.NewCatalog(skClient, config.SHKeeper.CurrencyTTL),
+			Rates:      rates.NewConverter(rates.NewCoinGecko(config.Rates.URL, config.Rates.APIKey), config.Rates.CacheTTL, config.Rates.QuoteValidity),
+			// viper lowercases map keys, currencies are matched uppercased
+			Expiry:     make(map[string]time.Duration),
+			LateWindow: config.Payments.LateWindow,
+		},
+	}
+	bot.Approvals = &handlers.Approvals{
+		Client:    client,
+		Agent:     aiAgent,
+		AdminRoom: id.RoomID(config.Admin.Room),
+		IsAdmin:   bot.isAdmin,
+		Timeout:   config.Admin.ApprovalTimeout,
+		// Spends reserve budget, pay from the SHKeeper wallet and then commit
+		Execute: (&handlers.Spends{SHKeeper: skClient}).Execute,
+		Alerts: &handlers.BudgetAlerts{
+			Client:     client,
+			Agent:      aiAgent,
+			Room:       id.RoomID(config.Alerts.Room),
+			Thresholds: config.Alerts.Thresholds,
+		},
+	}
+	if bot.Approvals.Alerts.Room == "" {
+		bot.Approvals.Alerts.Room = id.RoomID(config.Admin.Room)
+	}
+	for _, pct := range config.Alerts.Thresholds {
+		if pct <= 0 {
+			return nil, fmt.Errorf("invalid alerts.thresholds: %d is not a positive percentage", pct)
+		}
+	}
+	// Thresholds crossed before a restart were already announced
+	bot.Approvals.Alerts.Prime()
+	bot.Reports = &handlers.Reports{
+		Client: client,
+		Agent:  aiAgent,
+		Room:   id.RoomID(config.Reports.Room),
+	}
+	if bot.Reports.Room == "" {
+		bot.Reports.Room = id.RoomID(config.Admin.Room)
+	}
+	for _, name := range config.Reports.Periods {
+		period, err := agent.ParseReportPeriod(strings.ToLower(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reports.periods: %w", err)
+		}
+		bot.Reports.Periods = append(bot.Reports.Periods, period)
+	}
+	for currency, expiry := range config.Payments.Expiry {
+		bot.Payments.Expiry[strings.ToUpper(currency)] = expiry
+	}
+
+	// SHKeeper callbacks replace most of the polling when enabled
+	if config.Webhook.Listen != "" {
+		if config.Webhook.Secret == "" {
+			return nil, fmt.Errorf("webhook.secret is required when webhook.listen is set")
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/shkeeper/callback", &shkeeper.WebhookHandler{
+			Secret:    config.Webhook.Secret,
+			OnPayment: bot.Payments.HandleCallback,
+		})
+		bot.Webhook = &http.Server{
+			Addr:              config.Webhook.Listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		bot.Payments.CallbackURL = config.Webhook.URL
+		bot.Payments.PollInterval = config.Webhook.PollInterval
 	}
 
 	// Register handlers
 	bot.Handlers = handlers.NewRegistry()
 	bot.registerHandlers()
+	bot.Payments.Handlers = bot.Handlers
 
 	return bot, nil
 }
@@ -119,7 +513,7 @@ func (b *Bot) Start() error {
 			AccountData mautrix.FilterPart `json:"account_data,omitempty"`
 		}{
 			Timeline: mautrix.FilterPart{
-				Types: []event.Type{event.EventMessage},
+				Types: []event.Type{event.EventMessage, event.EventReaction},
 			},
 		},
 	}
@@ -129,6 +523,7 @@ func (b *Bot) Start() error {
 
 	// Set up event handlers
 	b.Client.Syncer.(*mautrix.DefaultSyncer).OnEventType(event.EventMessage, b.handleMessage)
+	b.Client.Syncer.(*mautrix.DefaultSyncer).OnEventType(event.EventReaction, b.handleReaction)
 	b.Client.Syncer.(*mautrix.DefaultSyncer).OnEventType(event.StateMember, b.handleMembership)
 
 	// Start syncing
@@ -142,6 +537,27 @@ func (b *Bot) Start() error {
 		}
 	}()
 
+	if b.Webhook != nil {
+		log.Info("🪝 Listening for SHKeeper callbacks", "addr", b.Webhook.Addr)
+		go func() {
+			if err := b.Webhook.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("Webhook server error", "error", err)
+			}
+		}()
+	}
+
+	// Pick up orders that were still waiting for payment before the restart
+	if err := b.Payments.Resume(); err != nil {
+		return err
+	}
+	if err := b.Refunds.Resume(); err != nil {
+		return err
+	}
+	if err := b.Approvals.Resume(); err != nil {
+		return err
+	}
+	b.Reports.Start()
+
 	// Set display name
 	_, _ = b.Client.SetDisplayName(context.Background(), "ClawClack Agent 🤖")
 
@@ -151,6 +567,27 @@ func (b *Bot) Start() error {
 
 func (b *Bot) Stop() {
 	b.Client.StopSync()
+	b.Reports.Stop()
+	if b.Webhook != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = b.Webhook.Shutdown(ctx)
+	}
+	if err := b.Orders.Close(); err != nil {
+		log.Error("Failed to close order store", "error", err)
+	}
+	if err := b.Refunds.Store.Close(); err != nil {
+		log.Error("Failed to close refund store", "error", err)
+	}
+	if err := b.Credits.Close(); err != nil {
+		log.Error("Failed to close credit store", "error", err)
+	}
+	if err := b.Payees.Close(); err != nil {
+		log.Error("Failed to close address book", "error", err)
+	}
+	if err := b.Ledger.Close(); err != nil {
+		log.Error("Failed to close ledger", "error", err)
+	}
 }
 
 func (b *Bot) handleMessage(source mautrix.EventSource, evt *event.Event) {
@@ -172,19 +609,44 @@ func (b *Bot) handleMessage(source mautrix.EventSource, evt *event.Event) {
 
 	// Route to appropriate handler
 	ctx := &handlers.Context{
-		Client:   b.Client,
-		RoomID:   roomID,
-		Sender:   sender,
-		Message:  content,
-		SHKeeper: b.SHKeeper,
-		Agent:    b.Agent,
+		Client:    b.Client,
+		RoomID:    roomID,
+		Sender:    sender,
+		Message:   content,
+		SHKeeper:  b.SHKeeper,
+		Agent:     b.Agent,
+		Orders:    b.Orders,
+		Payments:  b.Payments,
+		Refunds:   b.Refunds,
+		Approvals: b.Approvals,
+		Proposals: b.Proposals,
+		Payees:    b.Payees,
+		IsAdmin:   b.isAdmin(sender),
 	}
 
 	if handler := b.Handlers.Find(content); handler != nil {
 		go handler.Handle(ctx)
+	} else {
+		// Plain replies may answer an open !propose quote
+		go b.Proposals.HandleReply(ctx)
 	}
 }
 
+// handleReaction passes reactions on to the spend approval workflow
+func (b *Bot) handleReaction(source mautrix.EventSource, evt *event.Event) {
+	relates := evt.Content.AsReaction().RelatesTo
+	b.Approvals.HandleReaction(evt.RoomID, evt.Sender, relates.EventID, relates.Key)
+}
+
+func (b *Bot) isAdmin(userID id.UserID) bool {
+	for _, admin := range b.Config.Admin.Users {
+		if admin == userID.String() {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Bot) handleMembership(source mautrix.EventSource, evt *event.Event) {
 	if evt.GetStateKey() == b.Config.Matrix.UserID.String() {
 		if evt.Content.AsMember().Membership == event.MembershipInvite {
@@ -213,9 +675,7 @@ I offer AI-powered services and can help your group:
 • !price <crypto> - Get current crypto price
 
 **Paid services:**
-• !alert <crypto> <price> - Price alerts ($0.10)
 • !summarize <url> - Article summary ($0.50)
-• !image <prompt> - AI image generation ($0.75)
 • !code <description> - Code generation ($0.50)
 
 Type !help for more details.`
@@ -229,12 +689,46 @@ func (b *Bot) registerHandlers() {
 	b.Handlers.Register("!services", &handlers.ServicesHandler{})
 	b.Handlers.Register("!price", &handlers.PriceHandler{})
 	b.Handlers.Register("!alert", &handlers.AlertHandler{})
-	b.Handlers.Register("!summarize", &handlers.SummarizeHandler{})
+	b.Handlers.Register("!summarize", &handlers.SummarizeHandler{Fetcher: article.NewFetcher(b.Fetcher)})
 	b.Handlers.Register("!image", &handlers.ImageHandler{})
 	b.Handlers.Register("!code", &handlers.CodeHandler{})
 	b.Handlers.Register("!propose", &handlers.ProposeHandler{})
 	b.Handlers.Register("!pay", &handlers.PaymentHandler{})
 	b.Handlers.Register("!status", &handlers.StatusHandler{})
+	b.Handlers.Register("!cancel", &handlers.CancelHandler{})
+	b.Handlers.Register("!topup", &handlers.TopUpHandler{})
+	b.Handlers.Register("!credits", &handlers.CreditsHandler{})
+	b.Handlers.Register("!currency", &handlers.CurrencyHandler{})
+	b.Handlers.Register("!refundaddress", &handlers.RefundAddressHandler{})
+	b.Handlers.Register("!refund", &handlers.RefundsHandler{})
+	b.Handlers.Register("!report", &handlers.ReportHandler{})
+	b.Handlers.Register("!export", &handlers.ExportHandler{})
+	b.Handlers.Register("!spend", &handlers.SpendsHandler{})
+	b.Handlers.Register("!payee", &handlers.PayeesHandler{})
+}
+
+// newLLM creates the provider behind every AI-backed feature, or nil if none is configured
+func newLLM(config *Config) (agent.Provider, error) {
+	switch config.LLM.Provider {
+	case "none":
+		return nil, nil
+	case "stub":
+		log.Warn("Using the stub LLM provider, AI-backed services will echo their requests")
+		return &agent.Stub{}, nil
+	case "", "openai":
+		apiKey := config.LLM.APIKey
+		if apiKey == "" {
+			apiKey = config.Agent.OpenAIKey
+		}
+		// Self-hosted OpenAI-compatible servers may not need a key
+		if apiKey == "" && config.LLM.BaseURL == "" {
+			log.Warn("No LLM API key configured, AI-backed services are unavailable")
+			return nil, nil
+		}
+		return agent.NewOpenAI(config.LLM.BaseURL, apiKey, config.LLM.Model, config.LLM.Timeout), nil
+	default:
+		return nil, fmt.Errorf("invalid llm.provider %q, use openai, stub or none", config.LLM.Provider)
+	}
 }
 
 func loadConfig() *Config {
@@ -250,9 +744,25 @@ func loadConfig() *Config {
 
 	// Defaults
 	viper.SetDefault("log_level", "info")
+	viper.SetDefault("data_dir", "data")
+	viper.SetDefault("shkeeper.currency_ttl", "10m")
+	viper.SetDefault("webhook.poll_interval", "2m")
+	viper.SetDefault("rates.cache_ttl", "1m")
+	viper.SetDefault("rates.quote_validity", "15m")
+	viper.SetDefault("payments.late_window", "24h")
+	viper.SetDefault("refunds.auto_approve_usd", "1.00")
+	viper.SetDefault("admin.approval_timeout", "1h")
+	viper.SetDefault("proposals.ttl", "30m")
+	viper.SetDefault("fetch.max_bytes", 2<<20)
+	viper.SetDefault("fetch.timeout", "20s")
+	viper.SetDefault("fetch.max_redirects", 5)
 	viper.SetDefault("matrix.homeserver", "https://matrix.org")
-	viper.SetDefault("agent.spending_limit_usd", 1.0)
-	viper.SetDefault("agent.daily_budget_usd", 5.0)
+	viper.SetDefault("agent.spending_limit_usd", "1.00")
+	viper.SetDefault("agent.daily_budget_usd", "5.00")
+	viper.SetDefault("agent.time_zone", "UTC")
+	viper.SetDefault("llm.provider", "openai")
+	viper.SetDefault("llm.model", "gpt-4o-mini")
+	viper.SetDefault("llm.timeout", "60s")
 
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
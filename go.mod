@@ -3,8 +3,32 @@ module clawclack
 go 1.21
 
 require (
- github.com/charmbracelet/log v0.3.1
- github.com/google/uuid v1.6.0
- github.com/spf13/viper v1.18.2
- maunium.net/go/mautrix v0.18.1
+	github.com/charmbracelet/log v0.3.1
+	github.com/google/uuid v1.6.0
+	github.com/spf13/viper v1.18.2
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.22.0
+	golang.org/x/net v0.24.0
+	maunium.net/go/mautrix v0.18.1
+)
+
+require (
+	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
+	github.com/charmbracelet/lipgloss v0.9.1 // indirect
+	github.com/go-logfmt/logfmt v0.6.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/muesli/reflow v0.3.0 // indirect
+	github.com/muesli/termenv v0.15.2 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+	github.com/rs/zerolog v1.32.0 // indirect
+	github.com/tidwall/gjson v1.17.1 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+	github.com/tidwall/sjson v1.2.5 // indirect
+	go.mau.fi/util v0.4.2 // indirect
+	golang.org/x/exp v0.0.0-20240409090435-93d18d7e34b8 // indirect
+	golang.org/x/sys v0.19.0 // indirect
 )
@@ -1,143 +1,291 @@
 package agent
 
 import (
-	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+
+	"clawclack/pkg/money"
 )
 
 // Config for AI agent
 type Config struct {
-	SpendingLimitUSD float64
-	DailyBudgetUSD   float64
-	OpenAIKey        string
+	// Limits is the global cap across all categories
+	Limits
+	// Categories have their own limits on top of the global cap.
+	// Once any are configured, every spend must name one of them.
+	Categories map[string]Limits
+	// Location calendar periods are counted in (nil means UTC)
+	Location *time.Location
+	// LLM answers the AI-backed features; nil disables them
+	LLM    Provider
+	Ledger Ledger // persists transactions; nil keeps them in memory only
+	// Payees lists the addresses spends may be paid to; nil allows none
+	Payees AddressBook
+	// DynamicBudget replaces the fixed global DailyBudgetUSD when set
+	DynamicBudget *DynamicBudget
+	// Pricing bounds the prices quoted for custom services
+	Pricing PricingPolicy
+	// History supplies earlier accepted proposals to price against; nil skips it
+	History PriceHistory
 }
 
 // Agent represents the autonomous AI agent
 type Agent struct {
 	config        Config
 	spendingMutex sync.RWMutex
-	dailySpending map[string]float64 // Date string -> amount spent
 	lastSpendTime time.Time
 	transactions  []Transaction
+	index         map[string]int // transaction ID -> position in transactions
 }
 
 // Transaction records a spend/earn
 type Transaction struct {
-	ID          string
-	Type        string // spend, earn
-	Amount      float64
-	Currency    string
-	Description string
-	Timestamp   time.Time
-	Approved    bool
+	ID          string      `json:"id"`
+	Type        string      `json:"type"` // spend, earn, refund
+	Amount      money.Money `json:"amount"`
+	Description string      `json:"description"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Approved    bool        `json:"approved"`
+	Category    string      `json:"category,omitempty"` // spends only
+	Service     string      `json:"service,omitempty"`  // earnings only
+	Payee       string      `json:"payee,omitempty"`    // address a spend is paid to
+	// ValueUSD is the USD value at the time for amounts that are not USD
+	// or a stablecoin, zero if unknown
+	ValueUSD money.Decimal `json:"value_usd"`
+	// Spends over the limits wait for an admin; see RequestApproval
+	Status      string    `json:"status,omitempty"`
+	RequestedAt time.Time `json:"requested_at,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+	DecidedBy   string    `json:"decided_by,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// USD returns what the transaction was worth in USD when it was recorded
+func (t Transaction) USD() (money.Money, bool) {
+	if usd, ok := t.Amount.USDValue(); ok {
+		return usd, true
+	}
+	if !t.ValueUSD.IsZero() {
+		return money.New(t.ValueUSD, money.USD), true
+	}
+	return money.Money{}, false
+}
+
+// holdsBudget reports whether tx counts against the spending limits: it was
+// paid, or its payment is in flight
+func (t Transaction) holdsBudget() bool {
+	return t.Type == "spend" && (t.Approved || t.Status == StatusReserved)
 }
 
 // SpendingStats for reporting
 type SpendingStats struct {
-	SpentToday    float64
-	SpentTotal    float64
-	EarnedToday   float64
-	EarnedTotal   float64
+	SpentToday    money.Money
+	SpentTotal    money.Money
+	EarnedToday   money.Money
+	EarnedTotal   money.Money
+	RefundedTotal money.Money
 	TransactionCount int
 	LastSpendTime time.Time
+	Windows       []Window // global budget windows
+	Budget        Budget   // effective global daily budget
+	Categories    []CategoryStats
 }
 
 // New creates a new AI agent
 func New(config Config) *Agent {
 	return &Agent{
-		config:        config,
-		dailySpending: make(map[string]float64),
-		transactions:  make([]Transaction, 0),
+		config:       config,
+		transactions: make([]Transaction, 0),
+		index:        make(map[string]int),
+	}
+}
+
+// Load replays the ledger so spending from before a restart still counts
+// against every budget window. Call it before the bot accepts commands.
+func (a *Agent) Load() error {
+	if a.config.Ledger == nil {
+		return nil
+	}
+
+	txs, err := a.config.Ledger.Load(time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to replay ledger: %w", err)
+	}
+
+	a.spendingMutex.Lock()
+	defer a.spendingMutex.Unlock()
+
+	a.transactions = make([]Transaction, 0, len(txs))
+	a.index = make(map[string]int)
+	for _, tx := range txs {
+		a.apply(tx)
+	}
+
+	log.Info("📒 Replayed agent ledger",
+		"transactions", len(txs),
+		"spent_today", a.spentToday(time.Now()))
+	return nil
+}
+
+// record persists tx to the ledger and then applies it.
+// Callers must hold spendingMutex.
+func (a *Agent) record(tx Transaction) error {
+	if a.config.Ledger != nil {
+		if err := a.config.Ledger.Append(tx); err != nil {
+			return err
+		}
+	}
+	a.apply(tx)
+	return nil
+}
+
+// apply adds tx to the in-memory history. A transaction with a known ID
+// replaces the earlier version, which is how approval decisions are
+// replayed. Callers must hold spendingMutex.
+func (a *Agent) apply(tx Transaction) {
+	if i, ok := a.index[tx.ID]; ok {
+		a.transactions[i] = tx
+	} else {
+		a.index[tx.ID] = len(a.transactions)
+		a.transactions = append(a.transactions, tx)
+	}
+	if tx.Type == "spend" && tx.Approved && tx.Timestamp.After(a.lastSpendTime) {
+		a.lastSpendTime = tx.Timestamp
 	}
 }
 
-// CanSpend checks if agent can spend amount
-func (a *Agent) CanSpend(amount float64) (bool, string) {
+// CanSpend checks if agent can spend amount in category
+func (a *Agent) CanSpend(category string, amount money.Money) (bool, string) {
 	a.spendingMutex.RLock()
 	defer a.spendingMutex.RUnlock()
 
-	// Check per-transaction limit
-	if amount > a.config.SpendingLimitUSD {
-		return false, fmt.Sprintf("Amount $%.2f exceeds per-transaction limit of $%.2f", 
-			amount, a.config.SpendingLimitUSD)
+	if reason := a.checkLimits(category, amount); reason != "" {
+		return false, reason
 	}
+	return true, ""
+}
 
-	// Check daily budget
-	today := time.Now().Format("2006-01-02")
-	spentToday := a.dailySpending[today]
-	if spentToday+amount > a.config.DailyBudgetUSD {
-		return false, fmt.Sprintf("Daily budget exceeded. Spent: $%.2f, Budget: $%.2f, Requested: $%.2f",
-			spentToday, a.config.DailyBudgetUSD, amount)
+// checkLimits compares amount against the global cap and the category's own
+// limits and returns why it can't be spent, or "" if it can.
+// Callers must hold spendingMutex.
+func (a *Agent) checkLimits(category string, amount money.Money) string {
+	usd, ok := amount.USDValue()
+	if !ok {
+		return fmt.Sprintf("Cannot value %s in USD", amount)
 	}
 
-	return true, ""
+	if reason := a.exceeds("", a.limits(time.Now()), usd); reason != "" {
+		return reason
+	}
+
+	if len(a.config.Categories) == 0 {
+		return ""
+	}
+	limits, ok := a.config.Categories[category]
+	if !ok {
+		return fmt.Sprintf("Unknown spend category %q", category)
+	}
+	return a.exceeds(category, limits, usd)
 }
 
-// RecordSpend records a spending transaction
-func (a *Agent) RecordSpend(ctx context.Context, amount float64, currency, description string) (*Transaction, error) {
-	a.spendingMutex.Lock()
-	defer a.spendingMutex.Unlock()
+// exceeds checks usd against one set of limits. An empty category means the
+// global cap. Callers must hold spendingMutex.
+func (a *Agent) exceeds(category string, limits Limits, usd money.Money) string {
+	scope := ""
+	if category != "" {
+		scope = category + " "
+	}
 
-	// Double-check limits
-	today := time.Now().Format("2006-01-02")
-	spentToday := a.dailySpending[today]
-	
-	if amount > a.config.SpendingLimitUSD {
-		return nil, fmt.Errorf("amount exceeds spending limit")
+	// Check per-transaction limit
+	if limits.SpendingLimitUSD.Sign() > 0 && usd.Cmp(limits.SpendingLimitUSD) > 0 {
+		return fmt.Sprintf("Amount %s exceeds %sper-transaction limit of %s",
+			usd, scope, limits.SpendingLimitUSD)
 	}
-	
-	if spentToday+amount > a.config.DailyBudgetUSD {
-		return nil, fmt.Errorf("daily budget exceeded")
+
+	// Check every budget window
+	for _, w := range a.windows(time.Now(), category, limits) {
+		if w.Spent.Add(usd).Cmp(w.Limit) > 0 {
+			reason := fmt.Sprintf("%s %sbudget exceeded. Spent: %s, Budget: %s, Requested: %s",
+				w.Period.Label(), scope, w.Spent, w.Limit, usd)
+			if category == "" && w.Period == PeriodDay && a.config.DynamicBudget != nil {
+				reason += fmt.Sprintf(" (today's budget: %s)", a.dailyBudget(time.Now()).Explain())
+			}
+			return reason
+		}
 	}
 
-	// Record transaction
+	return ""
+}
+
+// spentToday returns the USD spent since the start of the calendar day.
+// Callers must hold spendingMutex.
+func (a *Agent) spentToday(now time.Time) money.Money {
+	start, _ := PeriodDay.bounds(now, a.config.location())
+	spent, _ := a.spentSince(start, "")
+	return spent
+}
+
+// RecordEarn records earnings for service. usd is what amount was worth,
+// or the zero Money if unknown; stablecoins are valued automatically.
+func (a *Agent) RecordEarn(service string, amount, usd money.Money, description string) {
+	a.spendingMutex.Lock()
+	defer a.spendingMutex.Unlock()
+
 	tx := Transaction{
 		ID:          generateID(),
-		Type:        "spend",
+		Type:        "earn",
+		Service:     service,
 		Amount:      amount,
-		Currency:    currency,
 		Description: description,
 		Timestamp:   time.Now(),
 		Approved:    true,
 	}
+	if usd.Currency == money.USD {
+		tx.ValueUSD = usd.Amount
+	}
 
-	a.transactions = append(a.transactions, tx)
-	a.dailySpending[today] += amount
-	a.lastSpendTime = tx.Timestamp
-
-	log.Info("💸 Agent spent money", 
-		"amount", amount, 
-		"currency", currency,
-		"description", description,
-		"remaining_today", a.config.DailyBudgetUSD-a.dailySpending[today])
+	if err := a.record(tx); err != nil {
+		// The money already arrived, so keep it in memory regardless
+		log.Error("Failed to write earning to ledger", "amount", amount, "error", err)
+		a.apply(tx)
+	}
 
-	return &tx, nil
+	log.Info("💰 Agent earned money",
+		"amount", amount,
+		"description", description)
 }
 
-// RecordEarn records earnings
-func (a *Agent) RecordEarn(amount float64, currency, description string) {
+// RecordRefund records money paid back to a customer. Refunds are kept
+// apart from spends so they never count against the agent's budget.
+// usd is what amount was worth, or the zero Money if unknown.
+func (a *Agent) RecordRefund(amount, usd money.Money, description string) {
 	a.spendingMutex.Lock()
 	defer a.spendingMutex.Unlock()
 
 	tx := Transaction{
 		ID:          generateID(),
-		Type:        "earn",
+		Type:        "refund",
 		Amount:      amount,
-		Currency:    currency,
 		Description: description,
 		Timestamp:   time.Now(),
 		Approved:    true,
 	}
+	if usd.Currency == money.USD {
+		tx.ValueUSD = usd.Amount
+	}
 
-	a.transactions = append(a.transactions, tx)
+	if err := a.record(tx); err != nil {
+		// The refund already left the wallet, so keep it in memory regardless
+		log.Error("Failed to write refund to ledger", "amount", amount, "error", err)
+		a.apply(tx)
+	}
 
-	log.Info("💰 Agent earned money",
+	log.Info("↩️ Agent refunded money",
 		"amount", amount,
-		"currency", currency,
 		"description", description)
 }
 
@@ -146,69 +294,59 @@ func (a *Agent) GetSpendingStats() SpendingStats {
 	a.spendingMutex.RLock()
 	defer a.spendingMutex.RUnlock()
 
-	today := time.Now().Format("2006-01-02")
-	spentToday := a.dailySpending[today]
+	now := time.Now()
+	spentToday := a.spentToday(now)
+	dayStart, _ := PeriodDay.bounds(now, a.config.location())
 
-	var spentTotal, earnedTotal float64
+	// Totals are in USD; amounts without a USD value are left out
+	spentTotal := money.New(money.Zero, money.USD)
+	earnedToday := money.New(money.Zero, money.USD)
+	earnedTotal := money.New(money.Zero, money.USD)
+	refundedTotal := money.New(money.Zero, money.USD)
 	for _, tx := range a.transactions {
-		if tx.Type == "spend" {
-			spentTotal += tx.Amount
-		} else if tx.Type == "earn" {
-			earnedTotal += tx.Amount
+		usd, ok := tx.USD()
+		if !ok {
+			continue
+		}
+		switch {
+		case tx.Type == "spend" && tx.Approved:
+			spentTotal = spentTotal.Add(usd)
+		case tx.Type == "earn":
+			earnedTotal = earnedTotal.Add(usd)
+			if !tx.Timestamp.Before(dayStart) {
+				earnedToday = earnedToday.Add(usd)
+			}
+		case tx.Type == "refund":
+			refundedTotal = refundedTotal.Add(usd)
 		}
 	}
 
 	return SpendingStats{
 		SpentToday:       spentToday,
 		SpentTotal:       spentTotal,
+		EarnedToday:      earnedToday,
 		EarnedTotal:      earnedTotal,
+		RefundedTotal:    refundedTotal,
 		TransactionCount: len(a.transactions),
 		LastSpendTime:    a.lastSpendTime,
+		Windows:          a.windows(now, "", a.limits(now)),
+		Budget:           a.dailyBudget(now),
+		Categories:       a.categoryStats(now),
 	}
 }
 
 // GetSpendingLimit returns per-transaction limit
-func (a *Agent) GetSpendingLimit() float64 {
+func (a *Agent) GetSpendingLimit() money.Money {
 	return a.config.SpendingLimitUSD
 }
 
-// GetDailyBudget returns daily budget
-func (a *Agent) GetDailyBudget() float64 {
-	return a.config.DailyBudgetUSD
-}
-
-// DecideServicePricing uses AI to price a custom service
-func (a *Agent) DecideServicePricing(ctx context.Context, serviceDescription string) (float64, string, error) {
-	// TODO: Integrate with OpenAI to analyze service complexity
-	// For now, use simple heuristic
-	
-	// This is where you'd call OpenAI API to:
-	// 1. Analyze the service description
-	// 2. Compare to historical pricing
-	// 3. Consider current demand
-	// 4. Return recommended price and reasoning
-
-	// Placeholder implementation - max $1 due to spending limit
-	basePrice := 0.50
-	
-	// Simple complexity scoring (capped at $1)
-	if len(serviceDescription) > 100 {
-		basePrice = 1.0
-	} else if len(serviceDescription) > 50 {
-		basePrice = 0.75
-	}
-
-	// Hard cap at spending limit ($1)
-	if basePrice > a.config.SpendingLimitUSD {
-		basePrice = a.config.SpendingLimitUSD
-	}
-
-	reasoning := fmt.Sprintf("Based on service complexity. Max price $%.2f due to agent spending limits.", a.config.SpendingLimitUSD)
-
-	return basePrice, reasoning, nil
+// GetDailyBudget returns the effective global daily budget
+func (a *Agent) GetDailyBudget() money.Money {
+	return a.DailyBudget().Limit
 }
 
-// generateID creates a simple unique ID
+// generateID creates a random transaction ID. Clock-based IDs can repeat
+// within one tick, and a repeated ID replaces the earlier transaction.
 func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return uuid.New().String()
 }
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/money"
+)
+
+// Spend approval statuses. Earnings, refunds and spends from before
+// approvals existed have no status.
+const (
+	StatusPending  = "pending"  // over the limits, waiting for an admin
+	StatusReserved = "reserved" // budget held while the payment is sent
+	StatusApproved = "approved" // paid, counts against the budget
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+	StatusFailed   = "failed" // reserved, but the payment was not sent
+)
+
+// ErrNotPending is returned when a spend request was already decided
+var ErrNotPending = errors.New("spend request is not pending")
+
+// RequestApproval records a spend to payee that exceeds the limits so an
+// admin can approve it. It does not count against the budget until approved.
+func (a *Agent) RequestApproval(category string, amount money.Money, payee, description string, timeout time.Duration) (*Transaction, error) {
+	if _, ok := amount.USDValue(); !ok {
+		return nil, fmt.Errorf("cannot value %s in USD", amount)
+	}
+	// Admins approve amounts, not destinations; those go through the address book
+	if err := a.checkPayee(payee, amount); err != nil {
+		return nil, err
+	}
+
+	a.spendingMutex.Lock()
+	defer a.spendingMutex.Unlock()
+
+	// Admins can lift limits, but not invent categories
+	if _, ok := a.config.Categories[category]; len(a.config.Categories) > 0 && !ok {
+		return nil, fmt.Errorf("unknown spend category %q", category)
+	}
+
+	now := time.Now()
+	tx := Transaction{
+		ID:          generateID(),
+		Type:        "spend",
+		Category:    category,
+		Payee:       payee,
+		Amount:      amount,
+		Description: description,
+		Timestamp:   now,
+		Status:      StatusPending,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(timeout),
+	}
+	if err := a.record(tx); err != nil {
+		return nil, fmt.Errorf("failed to record spend request: %w", err)
+	}
+
+	log.Info("🛂 Spend needs approval",
+		"transaction", tx.ID,
+		"amount", amount,
+		"category", category,
+		"description", description)
+	return &tx, nil
+}
+
+// Approve approves a pending spend and reserves its budget, like Reserve.
+// Only the first decision wins, so the caller that gets the transaction
+// back is the one that executes it.
+func (a *Agent) Approve(txID, approvedBy string) (*Transaction, error) {
+	// The payee may have been removed while the request was waiting
+	if tx, ok := a.Transaction(txID); ok {
+		if err := a.checkPayee(tx.Payee, tx.Amount); err != nil {
+			return nil, err
+		}
+	}
+	return a.decide(txID, func(tx *Transaction) {
+		tx.Status = StatusReserved
+		tx.DecidedBy = approvedBy
+		// The spend takes effect, and counts against the budget, today
+		tx.Timestamp = time.Now()
+	})
+}
+
+// Reject declines a pending spend
+func (a *Agent) Reject(txID, rejectedBy string) (*Transaction, error) {
+	return a.decide(txID, func(tx *Transaction) {
+		tx.Status = StatusRejected
+		tx.DecidedBy = rejectedBy
+	})
+}
+
+// Expire closes a pending spend nobody decided on in time
+func (a *Agent) Expire(txID string) (*Transaction, error) {
+	return a.decide(txID, func(tx *Transaction) {
+		tx.Status = StatusExpired
+	})
+}
+
+func (a *Agent) decide(txID string, fn func(tx *Transaction)) (*Transaction, error) {
+	tx, err := a.transition(txID, StatusPending, ErrNotPending, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("🛂 Spend request decided",
+		"transaction", tx.ID,
+		"status", tx.Status,
+		"by", tx.DecidedBy)
+	return tx, nil
+}
+
+// transition applies fn to a transaction in status from and records the
+// result. It returns notFrom if the transaction is in another status.
+func (a *Agent) transition(txID, from string, notFrom error, fn func(tx *Transaction)) (*Transaction, error) {
+	a.spendingMutex.Lock()
+	defer a.spendingMutex.Unlock()
+
+	i, ok := a.index[txID]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction %s", txID)
+	}
+	tx := a.transactions[i]
+	if tx.Status != from {
+		return nil, notFrom
+	}
+
+	fn(&tx)
+	if err := a.record(tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// PendingApprovals returns spend requests still waiting for an admin
+func (a *Agent) PendingApprovals() []Transaction {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+
+	var pending []Transaction
+	for _, tx := range a.transactions {
+		if tx.Status == StatusPending {
+			pending = append(pending, tx)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// Period is a window the agent's spending is budgeted over
+type Period string
+
+const (
+	PeriodDay     Period = "day"     // calendar day in Config.Location
+	PeriodRolling Period = "rolling" // the last 24 hours
+	PeriodWeek    Period = "week"    // calendar week starting Monday
+	PeriodMonth   Period = "month"   // calendar month
+)
+
+// Label names the period for messages
+func (p Period) Label() string {
+	switch p {
+	case PeriodDay:
+		return "Daily"
+	case PeriodRolling:
+		return "Rolling 24h"
+	case PeriodWeek:
+		return "Weekly"
+	case PeriodMonth:
+		return "Monthly"
+	}
+	return string(p)
+}
+
+// Window is how much of one period's budget is used
+type Window struct {
+	Period   Period
+	Category string // empty for the global cap
+	Limit    money.Money
+	Spent    money.Money
+	Start    time.Time
+	// ResetsAt is when the window starts over. For the rolling window it is
+	// when the oldest spend in it ages out, or zero if nothing was spent.
+	ResetsAt time.Time
+}
+
+// Remaining returns what is left of the budget, never below zero
+func (w Window) Remaining() money.Money {
+	left := w.Limit.Sub(w.Spent)
+	if left.Sign() < 0 {
+		return money.New(money.Zero, money.USD)
+	}
+	return left
+}
+
+// Limits caps spending per transaction and per budget window.
+// A zero limit is not enforced.
+type Limits struct {
+	SpendingLimitUSD money.Money // per transaction
+	DailyBudgetUSD   money.Money
+	RollingBudgetUSD money.Money
+	WeeklyBudgetUSD  money.Money
+	MonthlyBudgetUSD money.Money
+}
+
+// budgets returns the configured limit of every enabled period
+func (c Limits) budgets() []Window {
+	var windows []Window
+	for _, b := range []Window{
+		{Period: PeriodDay, Limit: c.DailyBudgetUSD},
+		{Period: PeriodRolling, Limit: c.RollingBudgetUSD},
+		{Period: PeriodWeek, Limit: c.WeeklyBudgetUSD},
+		{Period: PeriodMonth, Limit: c.MonthlyBudgetUSD},
+	} {
+		if b.Limit.Sign() > 0 {
+			windows = append(windows, b)
+		}
+	}
+	return windows
+}
+
+func (c Config) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// bounds returns when the period containing now started and when it resets
+func (p Period) bounds(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := now.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	switch p {
+	case PeriodRolling:
+		return now.Add(-24 * time.Hour), time.Time{}
+	case PeriodWeek:
+		// Weekday counts from Sunday; weeks here start on Monday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// Windows returns the state of every enabled global budget window
+func (a *Agent) Windows() []Window {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+	now := time.Now()
+	return a.windows(now, "", a.limits(now))
+}
+
+// windows computes the budget windows of limits at now, counting only spends
+// in category (or all spends if it is empty). Callers must hold spendingMutex.
+func (a *Agent) windows(now time.Time, category string, limits Limits) []Window {
+	windows := limits.budgets()
+	// A dynamic daily budget of zero still applies: nothing may be spent today
+	if category == "" && a.config.DynamicBudget != nil && limits.DailyBudgetUSD.Sign() == 0 {
+		windows = append([]Window{{Period: PeriodDay, Limit: limits.DailyBudgetUSD}}, windows...)
+	}
+	for i := range windows {
+		w := &windows[i]
+		w.Category = category
+		w.Start, w.ResetsAt = w.Period.bounds(now, a.config.location())
+
+		var oldest time.Time
+		w.Spent, oldest = a.spentSince(w.Start, category)
+		if w.Period == PeriodRolling && !oldest.IsZero() {
+			w.ResetsAt = oldest.Add(24 * time.Hour)
+		}
+	}
+	return windows
+}
+
+// spentSince returns the USD spent since start in category (or in all
+// categories if it is empty) and when the earliest of those spends happened.
+// Callers must hold spendingMutex.
+func (a *Agent) spentSince(start time.Time, category string) (money.Money, time.Time) {
+	spent := money.New(money.Zero, money.USD)
+	var oldest time.Time
+	for _, tx := range a.transactions {
+		if !tx.holdsBudget() || tx.Timestamp.Before(start) {
+			continue
+		}
+		if category != "" && tx.Category != category {
+			continue
+		}
+		usd, ok := tx.USD()
+		if !ok {
+			continue
+		}
+		spent = spent.Add(usd)
+		if oldest.IsZero() || tx.Timestamp.Before(oldest) {
+			oldest = tx.Timestamp
+		}
+	}
+	return spent, oldest
+}
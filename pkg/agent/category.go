@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"sort"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// Spend categories the agent budgets for. Categories are configured by
+// name, so these are only the ones the bot itself spends on.
+const (
+	CategoryMarketing = "marketing" // ads and promotions
+	CategoryHiring    = "hiring"    // other bots and services doing work for us
+	CategoryCompute   = "compute"   // LLM and API calls behind paid services
+)
+
+// uncategorized labels spends recorded before categories were configured
+const uncategorized = "uncategorized"
+
+// CategoryStats is the spending of one category
+type CategoryStats struct {
+	Name       string
+	SpentToday money.Money
+	SpentTotal money.Money
+	Limits     Limits
+	Windows    []Window
+}
+
+// categoryStats breaks spending down by category. Configured categories are
+// listed even if nothing was spent yet. Callers must hold spendingMutex.
+func (a *Agent) categoryStats(now time.Time) []CategoryStats {
+	dayStart, _ := PeriodDay.bounds(now, a.config.location())
+	zero := money.New(money.Zero, money.USD)
+
+	byName := make(map[string]*CategoryStats)
+	stats := func(name string) *CategoryStats {
+		if s, ok := byName[name]; ok {
+			return s
+		}
+		s := &CategoryStats{Name: name, SpentToday: zero, SpentTotal: zero}
+		byName[name] = s
+		return s
+	}
+
+	for name, limits := range a.config.Categories {
+		s := stats(name)
+		s.Limits = limits
+		s.Windows = a.windows(now, name, limits)
+	}
+
+	for _, tx := range a.transactions {
+		if !tx.holdsBudget() {
+			continue
+		}
+		usd, ok := tx.USD()
+		if !ok {
+			continue
+		}
+
+		name := tx.Category
+		if name == "" {
+			name = uncategorized
+		}
+		s := stats(name)
+		s.SpentTotal = s.SpentTotal.Add(usd)
+		if !tx.Timestamp.Before(dayStart) {
+			s.SpentToday = s.SpentToday.Add(usd)
+		}
+	}
+
+	result := make([]CategoryStats, 0, len(byName))
+	for _, s := range byName {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
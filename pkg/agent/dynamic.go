@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// DynamicBudget sets the global daily budget to a share of the agent's net
+// profit over the days before today, so it spends more as it earns more
+type DynamicBudget struct {
+	Percent money.Decimal // share of trailing profit, e.g. 20 for 20%
+	Days    int           // trailing days counted, today excluded (defaults to 7)
+	Floor   money.Money   // the budget never drops below this, even at a loss
+	Ceiling money.Money   // nor rises above this
+}
+
+func (d *DynamicBudget) days() int {
+	if d.Days <= 0 {
+		return 7
+	}
+	return d.Days
+}
+
+// Budget is the effective global daily budget and how it was derived
+type Budget struct {
+	Limit   money.Money
+	Dynamic bool // false for the fixed daily_budget_usd
+
+	// Set for dynamic budgets only
+	Profit  money.Money // net profit over the trailing days
+	Days    int
+	Percent money.Decimal
+	Share   money.Money // Percent of Profit, before the floor and ceiling
+	Floor   money.Money
+	Ceiling money.Money
+}
+
+// Explain describes how the budget was derived
+func (b Budget) Explain() string {
+	if !b.Dynamic {
+		return "fixed daily budget"
+	}
+
+	s := fmt.Sprintf("%s%% of %s net profit over the last %d days", b.Percent, b.Profit, b.Days)
+	switch {
+	case b.Share.Cmp(b.Floor) < 0:
+		s += fmt.Sprintf(" = %s, raised to the %s floor", b.Share, b.Floor)
+	case b.Share.Cmp(b.Ceiling) > 0:
+		s += fmt.Sprintf(" = %s, capped at the %s ceiling", b.Share, b.Ceiling)
+	}
+	return s
+}
+
+// DailyBudget returns today's effective global daily budget
+func (a *Agent) DailyBudget() Budget {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+	return a.dailyBudget(time.Now())
+}
+
+// dailyBudget derives the global daily budget of the day containing now.
+// Only whole days before today count, so today's spending does not shrink
+// its own budget. Callers must hold spendingMutex.
+func (a *Agent) dailyBudget(now time.Time) Budget {
+	d := a.config.DynamicBudget
+	if d == nil {
+		return Budget{Limit: a.config.DailyBudgetUSD}
+	}
+
+	today, _ := PeriodDay.bounds(now, a.config.location())
+	profit := a.profit(today.AddDate(0, 0, -d.days()), today)
+	share := money.New(profit.Amount.Mul(d.Percent).Div(money.NewFromInt(100)).Round(2), money.USD)
+
+	limit := share
+	if limit.Cmp(d.Floor) < 0 {
+		limit = d.Floor
+	}
+	if limit.Cmp(d.Ceiling) > 0 {
+		limit = d.Ceiling
+	}
+
+	return Budget{
+		Limit:   limit,
+		Dynamic: true,
+		Profit:  profit,
+		Days:    d.days(),
+		Percent: d.Percent,
+		Share:   share,
+		Floor:   d.Floor,
+		Ceiling: d.Ceiling,
+	}
+}
+
+// limits returns the global limits in effect at now, with the daily budget
+// derived from profit in dynamic mode. Callers must hold spendingMutex.
+func (a *Agent) limits(now time.Time) Limits {
+	limits := a.config.Limits
+	if a.config.DynamicBudget != nil {
+		limits.DailyBudgetUSD = a.dailyBudget(now).Limit
+	}
+	return limits
+}
+
+// profit returns earnings less paid spends and refunds from start up to end,
+// in USD. Callers must hold spendingMutex.
+func (a *Agent) profit(start, end time.Time) money.Money {
+	profit := money.New(money.Zero, money.USD)
+	for _, tx := range a.transactions {
+		if tx.Timestamp.Before(start) || !tx.Timestamp.Before(end) {
+			continue
+		}
+		usd, ok := tx.USD()
+		if !ok {
+			continue
+		}
+		switch {
+		case tx.Type == "earn":
+			profit = profit.Add(usd)
+		case tx.Type == "spend" && tx.Approved, tx.Type == "refund":
+			profit = profit.Sub(usd)
+		}
+	}
+	return profit
+}
@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"clawclack/pkg/money"
+)
+
+// ExportFormat is a file format the transaction history can be exported to
+type ExportFormat string
+
+const (
+	FormatCSV     ExportFormat = "csv"     // one row per transaction
+	FormatJSONL   ExportFormat = "jsonl"   // the ledger's own JSON Lines encoding
+	FormatJournal ExportFormat = "journal" // hledger / ledger-cli journal
+)
+
+// ParseExportFormat parses an export format name
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(s)); f {
+	case FormatCSV, FormatJSONL, FormatJournal:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, use csv, jsonl or journal", s)
+}
+
+// Extension returns the file name extension for the format
+func (f ExportFormat) Extension() string {
+	return "." + string(f)
+}
+
+// ContentType returns the MIME type of the format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	}
+	return "text/plain"
+}
+
+// DayRange parses an inclusive range of YYYY-MM-DD days in loc into the
+// midnight starting from and the midnight after to. An empty day leaves
+// that end open and returns the zero time for it.
+func DayRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = time.ParseInLocation("2006-01-02", from, loc); err != nil {
+			return start, end, fmt.Errorf("invalid date %q, use YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.ParseInLocation("2006-01-02", to, loc); err != nil {
+			return start, end, fmt.Errorf("invalid date %q, use YYYY-MM-DD", to)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return start, end, fmt.Errorf("%s is after %s", from, to)
+	}
+	return start, end, nil
+}
+
+// Location returns the time zone the agent's days are counted in
+func (a *Agent) Location() *time.Location {
+	return a.config.location()
+}
+
+// Transactions returns the transactions from from up to but excluding to,
+// oldest first. A zero from or to leaves that end of the range open.
+func (a *Agent) Transactions(from, to time.Time) []Transaction {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+
+	var result []Transaction
+	for _, tx := range a.transactions {
+		if !from.IsZero() && tx.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !tx.Timestamp.Before(to) {
+			continue
+		}
+		result = append(result, tx)
+	}
+	// Decided spends take the decision time, which can reorder them
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
+
+// Export writes txs to w in format, with times in loc
+func Export(w io.Writer, txs []Transaction, format ExportFormat, loc *time.Location) error {
+	switch format {
+	case FormatCSV:
+		return exportCSV(w, txs, loc)
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, tx := range txs {
+			if err := enc.Encode(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatJournal:
+		return exportJournal(w, txs, loc)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func exportCSV(w io.Writer, txs []Transaction, loc *time.Location) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{
+		"id", "time", "type", "status", "category", "service",
+		"amount", "currency", "value_usd", "description", "decided_by",
+	}); err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		value := ""
+		if usd, ok := tx.USD(); ok {
+			value = usd.Amount.String()
+		}
+		if err := out.Write([]string{
+			tx.ID,
+			tx.Timestamp.In(loc).Format(time.RFC3339),
+			tx.Type,
+			status(tx),
+			tx.Category,
+			tx.Service,
+			tx.Amount.Amount.String(),
+			tx.Amount.Currency,
+			value,
+			tx.Description,
+			tx.DecidedBy,
+		}); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// status fills in the status of entries recorded before approvals existed
+func status(tx Transaction) string {
+	if tx.Status == "" && tx.Approved {
+		return StatusApproved
+	}
+	return tx.Status
+}
+
+// exportJournal writes one balanced entry per transaction that moved money.
+// Pending, rejected and failed spends are left out. The treasury posting
+// holds the coin amount, with its USD value as a total price when known, and
+// the income or expense posting holds that USD value, so treasury accounts
+// only ever hold their own coin.
+func exportJournal(w io.Writer, txs []Transaction, loc *time.Location) error {
+	for _, tx := range txs {
+		var other string
+		// The treasury receives earnings and pays out spends and refunds
+		sign := 1
+		switch {
+		case tx.Type == "earn":
+			other = "income:services:" + account(tx.Service, "other")
+		case tx.Type == "spend" && tx.Approved:
+			other, sign = "expenses:"+account(tx.Category, uncategorized), -1
+		case tx.Type == "refund":
+			other, sign = "income:refunds", -1
+		default:
+			continue
+		}
+
+		coin, value := tx.Amount, tx.Amount
+		priced := ""
+		if usd, ok := tx.USD(); ok && tx.Amount.Currency != money.USD {
+			value = usd
+			priced = " @@ " + journalAmount(usd)
+		}
+		if sign < 0 {
+			coin.Amount = coin.Amount.Neg()
+		} else {
+			value.Amount = value.Amount.Neg()
+		}
+
+		description := strings.Join(strings.Fields(tx.Description), " ")
+		if _, err := fmt.Fprintf(w, "%s * %s  ; id:%s\n    %-40s  %s%s\n    %-40s  %s\n\n",
+			tx.Timestamp.In(loc).Format("2006-01-02"), description, tx.ID,
+			treasury(tx.Amount), journalAmount(coin), priced,
+			other, journalAmount(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journalAmount formats m for the journal. Commodities other than plain
+// letters, such as "USDT-TRC20", must be quoted for hledger and ledger.
+func journalAmount(m money.Money) string {
+	if m.Currency == money.USD {
+		return m.String()
+	}
+	commodity := m.Currency
+	for _, r := range commodity {
+		if !unicode.IsLetter(r) {
+			commodity = `"` + commodity + `"`
+			break
+		}
+	}
+	return m.Amount.String() + " " + commodity
+}
+
+// treasury is the asset account holding a currency
+func treasury(m money.Money) string {
+	return "assets:treasury:" + account(m.Currency, "unknown")
+}
+
+// account turns a name into a journal account name segment
+func account(name, fallback string) string {
+	name = strings.Trim(strings.ToLower(name), "!")
+	name = strings.Map(func(r rune) rune {
+		if r == ':' || r == ';' || r == ' ' || r == '\t' {
+			return '-'
+		}
+		return r
+	}, name)
+	if name == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// posting is one line of a journal entry
+type posting struct {
+	account   string
+	quantity  money.Decimal
+	commodity string
+	cost      money.Money // what the posting is worth in the entry, after its price
+}
+
+// parseJournal reads the entries exportJournal writes: a header line and
+// two postings, each with an amount and an optional "@@" total price
+func parseJournal(t *testing.T, journal string) [][]posting {
+	t.Helper()
+	var entries [][]posting
+	for _, block := range strings.Split(strings.TrimSpace(journal), "\n\n") {
+		lines := strings.Split(block, "\n")
+		var entry []posting
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				t.Fatalf("posting without an amount: %q", line)
+			}
+			p := posting{account: fields[0]}
+			p.quantity, p.commodity = parseJournalAmount(t, fields[1:])
+			p.cost = money.Money{Amount: p.quantity, Currency: p.commodity}
+			if i := indexOf(fields, "@@"); i >= 0 {
+				price, priceCommodity := parseJournalAmount(t, fields[i+1:])
+				if p.quantity.Sign() < 0 {
+					price = price.Neg()
+				}
+				p.cost = money.Money{Amount: price, Currency: priceCommodity}
+			}
+			entry = append(entry, p)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseJournalAmount reads "$1.50", "-$1.50", "0.5 BTC" or `10 "USDT-TRC20"`
+func parseJournalAmount(t *testing.T, fields []string) (money.Decimal, string) {
+	t.Helper()
+	if strings.Contains(fields[0], "$") {
+		d, err := money.ParseDecimal(strings.Replace(fields[0], "$", "", 1))
+		if err != nil {
+			t.Fatalf("bad USD amount %q: %v", fields[0], err)
+		}
+		return d, money.USD
+	}
+	if len(fields) < 2 {
+		t.Fatalf("amount without a commodity: %q", fields)
+	}
+	d, err := money.ParseDecimal(fields[0])
+	if err != nil {
+		t.Fatalf("bad amount %q: %v", fields[0], err)
+	}
+	return d, strings.Trim(fields[1], `"`)
+}
+
+func indexOf(fields []string, s string) int {
+	for i, f := range fields {
+		if f == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestExportJournalBalances(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	txs := []Transaction{
+		{ID: "1", Type: "earn", Service: "!code", Amount: money.MustParse("10", "USDT-TRC20"), Timestamp: at, Approved: true},
+		{ID: "2", Type: "earn", Service: "!summarize", Amount: money.MustParse("0.001", "BTC"), ValueUSD: money.MustParseDecimal("60"), Timestamp: at, Approved: true},
+		{ID: "3", Type: "earn", Service: "!pay", Amount: money.Dollars("4"), Timestamp: at, Approved: true},
+		{ID: "4", Type: "spend", Category: "hosting", Amount: money.MustParse("3", "USDT-TRC20"), Timestamp: at, Approved: true, Status: StatusApproved},
+		{ID: "5", Type: "refund", Amount: money.MustParse("0.0004", "BTC"), ValueUSD: money.MustParseDecimal("25"), Timestamp: at, Approved: true},
+		{ID: "6", Type: "spend", Category: "tools", Amount: money.MustParse("0.1", "ETH"), Timestamp: at, Approved: true, Status: StatusApproved},
+		{ID: "7", Type: "spend", Category: "hosting", Amount: money.MustParse("100", "USDT"), Timestamp: at, Status: StatusPending},
+		{ID: "8", Type: "refund", Amount: money.Dollars("1.50"), Timestamp: at, Approved: true},
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, txs, FormatJournal, time.UTC); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	entries := parseJournal(t, buf.String())
+	if len(entries) != 7 {
+		t.Fatalf("got %d entries, want 7 (the pending spend left out):\n%s", len(entries), buf.String())
+	}
+
+	commodities := make(map[string]string)
+	balances := make(map[string]money.Money)
+	for i, entry := range entries {
+		// Every entry balances once prices are applied
+		total := make(map[string]money.Decimal)
+		for _, p := range entry {
+			total[p.cost.Currency] = total[p.cost.Currency].Add(p.cost.Amount)
+		}
+		for commodity, sum := range total {
+			if !sum.IsZero() {
+				t.Errorf("entry %d does not balance in %s: off by %s", i+1, commodity, sum)
+			}
+		}
+
+		for _, p := range entry {
+			if seen, ok := commodities[p.account]; ok && seen != p.commodity {
+				t.Errorf("%s holds both %s and %s", p.account, seen, p.commodity)
+			}
+			commodities[p.account] = p.commodity
+			balances[p.account] = money.Money{Amount: balances[p.account].Amount.Add(p.quantity), Currency: p.commodity}
+		}
+	}
+
+	for account, commodity := range commodities {
+		if coin, ok := strings.CutPrefix(account, "assets:treasury:"); ok && !strings.EqualFold(coin, commodity) {
+			t.Errorf("%s holds %s", account, commodity)
+		}
+	}
+
+	want := map[string]string{
+		"assets:treasury:usdt-trc20": "7 USDT-TRC20",
+		"assets:treasury:btc":        "0.0006 BTC",
+		"assets:treasury:usd":        "$2.50",
+		"assets:treasury:eth":        "-0.1 ETH",
+		"income:services:code":       "-$10.00",
+		"income:services:summarize":  "-$60.00",
+		"income:services:pay":        "-$4.00",
+		"expenses:hosting":           "$3.00",
+		"expenses:tools":             "0.1 ETH",
+		"income:refunds":             "$26.50",
+	}
+	for account, balance := range want {
+		if got := balances[account].String(); got != balance {
+			t.Errorf("%s = %s, want %s", account, got, balance)
+		}
+	}
+	if len(balances) != len(want) {
+		t.Errorf("got accounts %v, want %d", balances, len(want))
+	}
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Ledger persists the agent's transactions so budgets survive restarts
+type Ledger interface {
+	// Append durably records tx before it takes effect
+	Append(tx Transaction) error
+	// Load returns every transaction at or after since, oldest first
+	Load(since time.Time) ([]Transaction, error)
+	Close() error
+}
+
+// FileLedger is an append-only JSON Lines file, one transaction per line.
+// Every append is synced to disk before it returns.
+type FileLedger struct {
+	path     string
+	mu       sync.Mutex
+	file     *os.File
+	readOnly bool
+}
+
+// OpenFileLedger opens (or creates) the ledger file at path
+func OpenFileLedger(path string) (*FileLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+
+	// A crash mid-append can leave a torn last line. Its Append never
+	// returned, so nothing acted on it; cut it off so the next entry starts
+	// on a fresh line and every line before the end must decode.
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		end, err := lastNewline(file, info.Size())
+		if err == nil && end < info.Size() {
+			log.Warn("Dropping incomplete entry at the end of the ledger", "path", path, "bytes", info.Size()-end)
+			err = file.Truncate(end)
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to repair ledger: %w", err)
+		}
+	}
+
+	return &FileLedger{path: path, file: file}, nil
+}
+
+// OpenFileLedgerReadOnly opens the ledger file at path for reading only,
+// for tools such as export that may run next to the bot. It never repairs
+// the file, and Append fails.
+func OpenFileLedgerReadOnly(path string) (*FileLedger, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	return &FileLedger{path: path, file: file, readOnly: true}, nil
+}
+
+// lastNewline returns the offset just past the last newline in file, or 0
+// if there is none
+func lastNewline(file *os.File, size int64) (int64, error) {
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		n, err := file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// Append writes tx as one line and syncs it
+func (l *FileLedger) Append(tx Transaction) error {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.readOnly {
+		return fmt.Errorf("ledger %s is open read-only", l.path)
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync ledger: %w", err)
+	}
+	return nil
+}
+
+// Load reads the ledger from the start
+func (l *FileLedger) Load(since time.Time) ([]Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return readLedger(l.file, l.path, since)
+}
+
+// readLedger decodes transactions at or after since. Only a torn last line,
+// one without its newline, is skipped: a corrupt line anywhere else could
+// hide a spend and understate the budget used, so it fails the read.
+func readLedger(r io.Reader, path string, since time.Time) ([]Transaction, error) {
+	var result []Transaction
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read ledger: %w", err)
+		}
+		torn := err == io.EOF
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			var tx Transaction
+			if uerr := json.Unmarshal(data, &tx); uerr != nil {
+				if !torn {
+					return nil, fmt.Errorf("ledger %s is corrupt at line %d, fix or remove that line: %w", path, line, uerr)
+				}
+				log.Warn("Skipping incomplete entry at the end of the ledger", "path", path, "line", line, "error", uerr)
+			} else if !tx.Timestamp.Before(since) {
+				result = append(result, tx)
+			}
+		}
+		if torn {
+			return result, nil
+		}
+	}
+}
+
+// Close closes the ledger file
+func (l *FileLedger) Close() error {
+	return l.file.Close()
+}
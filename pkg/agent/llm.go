@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// DefaultOpenAIBaseURL is used when an OpenAI provider has no base URL
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// ErrNoLLM is returned by AI-backed features when no provider is configured
+var ErrNoLLM = errors.New("no LLM provider configured")
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a chat with an LLM
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionRequest asks a provider to continue a chat
+type CompletionRequest struct {
+	Messages []Message
+	Model    string // overrides the provider's model when set
+	// MaxTokens caps the reply; zero leaves it to the provider
+	MaxTokens int
+	// Temperature; zero leaves it to the provider
+	Temperature float64
+	// JSON asks for a single JSON object as the reply
+	JSON bool
+}
+
+// Completion is a provider's reply
+type Completion struct {
+	Content          string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider answers the agent's AI-backed features. Every feature goes
+// through Agent.Complete so vendors can be swapped in one place.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+}
+
+// Complete sends req to the configured provider
+func (a *Agent) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if a.config.LLM == nil {
+		return nil, ErrNoLLM
+	}
+
+	started := time.Now()
+	completion, err := a.config.LLM.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.config.LLM.Name(), err)
+	}
+	log.Debug("LLM completion", "provider", a.config.LLM.Name(), "model", completion.Model,
+		"prompt_tokens", completion.PromptTokens, "completion_tokens", completion.CompletionTokens,
+		"took", time.Since(started).Round(time.Millisecond))
+	return completion, nil
+}
+
+// jsonAttempts includes one retry after a reply that fails its schema
+const jsonAttempts = 2
+
+// CompleteJSON asks for a single JSON object matching schema and decodes
+// it into v. A reply that does not match is sent back with the validation
+// error for one more try.
+func (a *Agent) CompleteJSON(ctx context.Context, req CompletionRequest, schema *Schema, v any) error {
+	req.JSON = true
+	req.Messages = append(append([]Message(nil), req.Messages...), Message{
+		Role:    RoleSystem,
+		Content: "Answer with a single JSON object matching this JSON schema:\n" + schema.String(),
+	})
+
+	var err error
+	for attempt := 1; attempt <= jsonAttempts; attempt++ {
+		var completion *Completion
+		if completion, err = a.Complete(ctx, req); err != nil {
+			return err
+		}
+
+		content := completion.Content
+		// Some models wrap JSON in a code fence despite being asked not to
+		if start, end := strings.Index(content, "{"), strings.LastIndex(content, "}"); start >= 0 && end > start {
+			content = content[start : end+1]
+		}
+		if err = schema.Validate([]byte(content)); err == nil {
+			if err = json.Unmarshal([]byte(content), v); err == nil {
+				return nil
+			}
+		}
+
+		log.Debug("LLM reply rejected", "attempt", attempt, "error", err)
+		req.Messages = append(req.Messages,
+			Message{Role: RoleAssistant, Content: completion.Content},
+			Message{Role: RoleUser, Content: fmt.Sprintf("That reply is invalid: %v. Answer again with only the JSON object.", err)})
+	}
+	return fmt.Errorf("invalid reply: %w", err)
+}
+
+// HasLLM reports whether a provider is configured
+func (a *Agent) HasLLM() bool {
+	return a.config.LLM != nil
+}
+
+// OpenAI talks to the OpenAI chat completions API or any server that
+// implements it, such as a local model behind an OpenAI-compatible proxy
+type OpenAI struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	client  *http.Client
+}
+
+// NewOpenAI creates an OpenAI-compatible provider; an empty baseURL means OpenAI itself
+func NewOpenAI(baseURL, apiKey, model string, timeout time.Duration) *OpenAI {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return &OpenAI{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Model:   model,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Temperature    float64         `json:"temperature,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete calls POST {BaseURL}/chat/completions
+func (o *OpenAI) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	model := req.Model
+	if model == "" {
+		model = o.Model
+	}
+	body := chatRequest{
+		Model:       model,
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if req.JSON {
+		body.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.BaseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode completion: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != nil && result.Error.Message != "" {
+			return nil, fmt.Errorf("status %d: %s", resp.StatusCode, result.Error.Message)
+		}
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 {
+		return nil, errors.New("completion has no choices")
+	}
+
+	return &Completion{
+		Content:          strings.TrimSpace(result.Choices[0].Message.Content),
+		Model:            result.Model,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+	}, nil
+}
+
+// Stub is a deterministic provider for tests and offline runs. It never
+// calls out and remembers every request it was sent.
+type Stub struct {
+	// Reply answers a request; nil echoes the last user message
+	Reply func(req CompletionRequest) (string, error)
+
+	mu       sync.Mutex
+	requests []CompletionRequest
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+// Complete answers req with Reply
+func (s *Stub) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	var content string
+	if s.Reply != nil {
+		var err error
+		if content, err = s.Reply(req); err != nil {
+			return nil, err
+		}
+	} else {
+		content = "stub reply: " + lastUserMessage(req.Messages)
+	}
+
+	return &Completion{Content: content, Model: "stub"}, nil
+}
+
+// Requests returns the requests the stub has answered, oldest first
+func (s *Stub) Requests() []CompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CompletionRequest(nil), s.requests...)
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompleteWithoutProvider(t *testing.T) {
+	a := New(Config{})
+	if a.HasLLM() {
+		t.Error("HasLLM = true without a provider")
+	}
+	if _, err := a.Complete(context.Background(), CompletionRequest{}); !errors.Is(err, ErrNoLLM) {
+		t.Errorf("Complete = %v, want ErrNoLLM", err)
+	}
+}
+
+func TestStub(t *testing.T) {
+	stub := &Stub{}
+	a := New(Config{LLM: stub})
+
+	req := CompletionRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "first"},
+		{Role: RoleAssistant, Content: "ok"},
+		{Role: RoleUser, Content: "second"},
+	}}
+	completion, err := a.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if completion.Content != "stub reply: second" || completion.Model != "stub" {
+		t.Errorf("Complete = %q from %s, want an echo of the last user message", completion.Content, completion.Model)
+	}
+
+	// Provider errors are wrapped with the provider's name
+	stub.Reply = func(CompletionRequest) (string, error) { return "", context.DeadlineExceeded }
+	if _, err := a.Complete(context.Background(), req); !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(err.Error(), "stub: ") {
+		t.Errorf("Complete = %v, want the stub's error", err)
+	}
+
+	if requests := stub.Requests(); len(requests) != 2 || len(requests[0].Messages) != 4 {
+		t.Errorf("stub remembered %d requests, want both", len(requests))
+	}
+}
+
+func TestOpenAIComplete(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key provided"}}`))
+			return
+		}
+		got = chatRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch got.Messages[len(got.Messages)-1].Content {
+		case "nothing":
+			w.Write([]byte(`{"model": "gpt-test", "choices": []}`))
+		case "overloaded":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`<html>busy</html>`))
+		default:
+			w.Write([]byte(`{"model": "gpt-test-0613", "choices": [{"message": {"role": "assistant", "content": "  hello \n"}, "finish_reason": "stop"}],
+				"usage": {"prompt_tokens": 12, "completion_tokens": 3}}`))
+		}
+	}))
+	defer srv.Close()
+
+	o := NewOpenAI(srv.URL+"/v1/", "sk-test", "gpt-test", 0)
+	completion, err := o.Complete(context.Background(), CompletionRequest{
+		Messages:  []Message{{Role: RoleUser, Content: "hi"}},
+		MaxTokens: 50,
+		JSON:      true,
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if completion.Content != "hello" || completion.Model != "gpt-test-0613" ||
+		completion.PromptTokens != 12 || completion.CompletionTokens != 3 {
+		t.Errorf("Complete = %+v, want the trimmed reply with its usage", completion)
+	}
+	if got.Model != "gpt-test" || got.MaxTokens != 50 || got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Errorf("sent %+v, want the default model, max tokens and JSON mode", got)
+	}
+
+	// The request's model overrides the provider's
+	if _, err := o.Complete(context.Background(), CompletionRequest{Model: "gpt-other", Messages: []Message{{Role: RoleUser, Content: "hi"}}}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got.Model != "gpt-other" || got.ResponseFormat != nil {
+		t.Errorf("sent model %s with %+v, want gpt-other without JSON mode", got.Model, got.ResponseFormat)
+	}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		message string
+		want    string
+	}{
+		{"API error", "sk-wrong", "hi", "status 401: Incorrect API key provided"},
+		{"error without a body", "sk-test", "overloaded", "status 503"},
+		{"no choices", "sk-test", "nothing", "completion has no choices"},
+	}
+	for _, tt := range tests {
+		o := NewOpenAI(srv.URL+"/v1", tt.apiKey, "gpt-test", 0)
+		_, err := o.Complete(context.Background(), CompletionRequest{Messages: []Message{{Role: RoleUser, Content: tt.message}}})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: Complete = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/money"
+)
+
+const (
+	// similarProposals caps how many earlier proposals a price is blended with
+	similarProposals = 5
+	// minSimilarity is the word overlap a proposal needs to count as similar
+	minSimilarity = 0.3
+)
+
+// estimateSchema is what the LLM must answer a pricing request with
+var estimateSchema = MustParseSchema(`{
+	"type": "object",
+	"required": ["complexity", "compute_cost_usd", "suggested_price_usd", "reasoning"],
+	"additionalProperties": false,
+	"properties": {
+		"complexity": {"type": "integer", "minimum": 1, "maximum": 5, "description": "1 trivial to 5 very complex"},
+		"compute_cost_usd": {"type": "number", "minimum": 0, "description": "expected cost of the LLM and API calls needed to deliver it"},
+		"suggested_price_usd": {"type": "number", "minimum": 0, "description": "fair price for the customer"},
+		"reasoning": {"type": "string", "minLength": 1, "maxLength": 400, "description": "one or two plain sentences for the customer"}
+	}
+}`)
+
+// PricingPolicy bounds the prices the agent quotes for custom services
+type PricingPolicy struct {
+	Min money.Money // defaults to $0.50
+	Max money.Money // defaults to the per-transaction spending limit; zero there too means no cap
+	// Margin over the expected compute cost, in percent, that counter-offers
+	// must leave (defaults to 50). They never go below Min either.
+	Margin money.Decimal
+}
+
+// PriceHistory looks up what customers paid for earlier custom services
+type PriceHistory interface {
+	AcceptedPrices() ([]AcceptedPrice, error)
+}
+
+// AcceptedPrice is a custom service a customer paid for
+type AcceptedPrice struct {
+	Description string
+	Price       money.Money // USD
+	AcceptedAt  time.Time
+}
+
+// PriceEstimate is a quoted price and how the agent arrived at it
+type PriceEstimate struct {
+	Price money.Money
+
+	// From the LLM, or the length heuristic when Fallback is set
+	Complexity  int // 1 to 5, 0 if unknown
+	ComputeCost money.Money
+	Suggested   money.Money
+	Rationale   string
+	Fallback    bool
+
+	Similar []AcceptedPrice // earlier proposals the price was blended with
+	History money.Money     // their average, weighted by similarity
+	Blended money.Money     // Suggested blended with History
+
+	Clamped string // why the price was moved to a policy bound, if it was
+
+	// Floor is the lowest counter-offer worth accepting: the compute cost
+	// plus the policy margin, at least the policy minimum and at most Price
+	Floor money.Money
+}
+
+// Reasoning explains the price to the customer
+func (e *PriceEstimate) Reasoning() string {
+	var parts []string
+	if e.Fallback {
+		parts = append(parts, fmt.Sprintf("Rough estimate from the length of your request: %s.", e.Suggested))
+	} else {
+		parts = append(parts, e.Rationale)
+		parts = append(parts, fmt.Sprintf("Complexity %d/5 with about %s of compute, suggested at %s.", e.Complexity, e.ComputeCost, e.Suggested))
+	}
+	if len(e.Similar) > 0 {
+		noun := "proposals"
+		if len(e.Similar) == 1 {
+			noun = "proposal"
+		}
+		parts = append(parts, fmt.Sprintf("%d similar accepted %s averaged %s, blended to %s.", len(e.Similar), noun, e.History, e.Blended))
+	}
+	if e.Clamped != "" {
+		parts = append(parts, e.Clamped+".")
+	}
+	return strings.Join(parts, " ")
+}
+
+// DecideServicePricing prices a custom service, see EstimatePrice
+func (a *Agent) DecideServicePricing(ctx context.Context, serviceDescription string) (money.Money, string, error) {
+	est, err := a.EstimatePrice(ctx, serviceDescription)
+	if err != nil {
+		return money.Money{}, "", err
+	}
+	return est.Price, est.Reasoning(), nil
+}
+
+// EstimatePrice asks the LLM for a structured estimate of the service,
+// blends its suggestion with what customers paid for similar proposals and
+// clamps the result to the pricing policy. Without a usable LLM reply the
+// old length heuristic stands in for the estimate.
+func (a *Agent) EstimatePrice(ctx context.Context, description string) (*PriceEstimate, error) {
+	minPrice, maxPrice := a.priceBounds()
+
+	est, err := a.llmEstimate(ctx, description, minPrice, maxPrice)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrNoLLM) {
+			log.Warn("LLM pricing failed, using the length heuristic", "error", err)
+		}
+		est = heuristicEstimate(description)
+	}
+
+	est.Blended = est.Suggested
+	est.Similar, est.History = a.similarPrices(description)
+	if n := int64(len(est.Similar)); n > 0 {
+		// Each similar proposal shifts the price further towards history:
+		// one counts for a third, two for half, five for 5/7
+		weight := money.NewFromInt(n).Div(money.NewFromInt(n + 2))
+		blended := est.Suggested.Amount.Mul(money.NewFromInt(1).Sub(weight)).Add(est.History.Amount.Mul(weight))
+		est.Blended = money.New(blended.Round(2), money.USD)
+	}
+
+	est.Price = est.Blended
+	// Never quote below what delivering the service costs
+	floor := minPrice
+	if cost := money.New(est.ComputeCost.Amount.RoundUp(2), money.USD); cost.Cmp(floor) > 0 {
+		floor = cost
+	}
+	if est.Price.Cmp(floor) < 0 {
+		est.Price = floor
+		est.Clamped = fmt.Sprintf("Raised to the %s minimum", floor)
+	}
+	if maxPrice.Sign() > 0 && est.Price.Cmp(maxPrice) > 0 {
+		est.Price = maxPrice
+		est.Clamped = fmt.Sprintf("Capped at the %s maximum", maxPrice)
+		if floor.Cmp(maxPrice) > 0 {
+			est.Clamped += ", below the expected cost"
+		}
+	}
+
+	margin := a.config.Pricing.Margin
+	if margin.IsZero() {
+		margin = money.NewFromInt(50)
+	}
+	est.Floor = money.New(est.ComputeCost.Amount.Mul(margin.Add(money.NewFromInt(100))).Div(money.NewFromInt(100)).RoundUp(2), money.USD)
+	if est.Floor.Cmp(minPrice) < 0 {
+		est.Floor = minPrice
+	}
+	if est.Floor.Cmp(est.Price) > 0 {
+		est.Floor = est.Price
+	}
+	return est, nil
+}
+
+// priceBounds returns the policy bounds with their defaults; a zero max means no cap
+func (a *Agent) priceBounds() (money.Money, money.Money) {
+	minPrice := a.config.Pricing.Min
+	if minPrice.IsZero() {
+		minPrice = money.Dollars("0.50")
+	}
+	maxPrice := a.config.Pricing.Max
+	if maxPrice.IsZero() {
+		maxPrice = a.config.SpendingLimitUSD
+	}
+	return minPrice, maxPrice
+}
+
+// llmEstimate asks the LLM for an estimate matching estimateSchema
+func (a *Agent) llmEstimate(ctx context.Context, description string, minPrice, maxPrice money.Money) (*PriceEstimate, error) {
+	bounds := fmt.Sprintf("Prices start at %s.", minPrice)
+	if maxPrice.Sign() > 0 {
+		bounds = fmt.Sprintf("Prices range from %s to %s.", minPrice, maxPrice)
+	}
+
+	var reply struct {
+		Complexity        int           `json:"complexity"`
+		ComputeCostUSD    money.Decimal `json:"compute_cost_usd"`
+		SuggestedPriceUSD money.Decimal `json:"suggested_price_usd"`
+		Reasoning         string        `json:"reasoning"`
+	}
+	err := a.CompleteJSON(ctx, CompletionRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: "You price custom services sold by an autonomous agent in a chat room. " +
+				"Estimate the work needed to deliver the customer's request. " + bounds},
+			{Role: RoleUser, Content: description},
+		},
+		MaxTokens: 400,
+	}, estimateSchema, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("price estimate: %w", err)
+	}
+
+	return &PriceEstimate{
+		Complexity:  reply.Complexity,
+		ComputeCost: money.New(reply.ComputeCostUSD, money.USD),
+		Suggested:   money.New(reply.SuggestedPriceUSD.Round(2), money.USD),
+		Rationale:   strings.TrimSpace(reply.Reasoning),
+	}, nil
+}
+
+// heuristicEstimate prices by the length of the description
+func heuristicEstimate(description string) *PriceEstimate {
+	suggested := money.Dollars("0.50")
+	if len(description) > 100 {
+		suggested = money.Dollars("1.00")
+	} else if len(description) > 50 {
+		suggested = money.Dollars("0.75")
+	}
+	return &PriceEstimate{
+		ComputeCost: money.New(money.Zero, money.USD),
+		Suggested:   suggested,
+		Fallback:    true,
+	}
+}
+
+// similarPrices returns the accepted proposals most like description and
+// their average price, weighted by how similar they are
+func (a *Agent) similarPrices(description string) ([]AcceptedPrice, money.Money) {
+	zero := money.New(money.Zero, money.USD)
+	if a.config.History == nil {
+		return nil, zero
+	}
+	accepted, err := a.config.History.AcceptedPrices()
+	if err != nil {
+		log.Warn("Failed to load accepted proposal prices", "error", err)
+		return nil, zero
+	}
+
+	type scored struct {
+		AcceptedPrice
+		score float64
+	}
+	words := keywords(description)
+	var matches []scored
+	for _, p := range accepted {
+		if p.Price.Currency != money.USD || p.Price.Sign() <= 0 {
+			continue
+		}
+		if score := similarity(words, keywords(p.Description)); score >= minSimilarity {
+			matches = append(matches, scored{p, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].AcceptedAt.After(matches[j].AcceptedAt)
+	})
+	if len(matches) > similarProposals {
+		matches = matches[:similarProposals]
+	}
+	if len(matches) == 0 {
+		return nil, zero
+	}
+
+	// Scores become whole-number weights so the average stays exact
+	total, sum := money.Zero, money.Zero
+	similar := make([]AcceptedPrice, len(matches))
+	for i, m := range matches {
+		weight := money.NewFromInt(int64(m.score*100 + 0.5))
+		total = total.Add(weight)
+		sum = sum.Add(m.Price.Amount.Mul(weight))
+		similar[i] = m.AcceptedPrice
+	}
+	return similar, money.New(sum.Div(total).Round(2), money.USD)
+}
+
+// stopWords carry no meaning for comparing service requests
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"from": true, "into": true, "need": true, "want": true, "can": true, "you": true,
+	"please": true, "some": true, "which": true, "are": true, "will": true, "would": true,
+}
+
+// keywords returns the distinct meaningful words of s
+func keywords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) >= 3 && !stopWords[w] {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// similarity is the Jaccard index of two keyword sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// estimateReply answers a pricing request with a fixed estimate
+func estimateReply(cost, suggested string) func(CompletionRequest) (string, error) {
+	return func(CompletionRequest) (string, error) {
+		return fmt.Sprintf(`{"complexity": 3, "compute_cost_usd": %s, "suggested_price_usd": %s, "reasoning": "Some work."}`,
+			cost, suggested), nil
+	}
+}
+
+type fixedHistory []AcceptedPrice
+
+func (h fixedHistory) AcceptedPrices() ([]AcceptedPrice, error) {
+	return h, nil
+}
+
+func TestEstimatePrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     func(CompletionRequest) (string, error)
+		noLLM     bool
+		config    Config
+		history   PriceHistory
+		idea      string
+		wantPrice string
+		wantFloor string
+		clamped   string // substring of Clamped, "" for none
+		fallback  bool
+	}{
+		{
+			name:      "suggestion within bounds",
+			reply:     estimateReply("0.20", "2.00"),
+			wantPrice: "$2.00",
+			wantFloor: "$0.50", // 0.20 plus 50% is below the minimum
+		},
+		{
+			name:      "suggestion rounded to cents",
+			reply:     estimateReply("0.10", "1.234"),
+			wantPrice: "$1.23",
+			wantFloor: "$0.50",
+		},
+		{
+			name:      "raised to the policy minimum",
+			reply:     estimateReply("0.01", "0.10"),
+			wantPrice: "$0.50",
+			wantFloor: "$0.50",
+			clamped:   "Raised to the $0.50 minimum",
+		},
+		{
+			name:      "raised to the compute cost",
+			reply:     estimateReply("1.201", "1.00"),
+			wantPrice: "$1.21",
+			wantFloor: "$1.21", // cost plus margin, capped at the price
+			clamped:   "Raised to the $1.21 minimum",
+		},
+		{
+			name:      "capped at the policy maximum",
+			reply:     estimateReply("0.50", "50.00"),
+			config:    Config{Pricing: PricingPolicy{Max: money.Dollars("10.00")}},
+			wantPrice: "$10.00",
+			wantFloor: "$0.75",
+			clamped:   "Capped at the $10.00 maximum",
+		},
+		{
+			name:      "capped at the spending limit without a policy maximum",
+			reply:     estimateReply("0.50", "50.00"),
+			config:    Config{Limits: Limits{SpendingLimitUSD: money.Dollars("5.00")}},
+			wantPrice: "$5.00",
+			wantFloor: "$0.75",
+			clamped:   "Capped at the $5.00 maximum",
+		},
+		{
+			name:      "capped below the expected cost",
+			reply:     estimateReply("20.00", "30.00"),
+			config:    Config{Pricing: PricingPolicy{Max: money.Dollars("10.00")}},
+			wantPrice: "$10.00",
+			wantFloor: "$10.00",
+			clamped:   "below the expected cost",
+		},
+		{
+			name:      "custom minimum and margin",
+			reply:     estimateReply("1.00", "4.00"),
+			config:    Config{Pricing: PricingPolicy{Min: money.Dollars("1.00"), Margin: money.NewFromInt(100)}},
+			wantPrice: "$4.00",
+			wantFloor: "$2.00",
+		},
+		{
+			name:      "floor rounded up to cents",
+			reply:     estimateReply("0.401", "3.00"),
+			wantPrice: "$3.00",
+			wantFloor: "$0.61",
+		},
+		{
+			name:  "blended with a similar accepted proposal",
+			reply: estimateReply("0.10", "2.00"),
+			history: fixedHistory{
+				{Description: "python script scrape prices", Price: money.Dollars("5.00"), AcceptedAt: time.Now()},
+				{Description: "translate poem into french", Price: money.Dollars("90.00"), AcceptedAt: time.Now()},
+			},
+			idea:      "python script to scrape prices",
+			wantPrice: "$3.00", // one match counts for a third
+			wantFloor: "$0.50",
+		},
+		{
+			name:      "invalid replies fall back to the heuristic",
+			reply:     func(CompletionRequest) (string, error) { return "about two dollars", nil },
+			wantPrice: "$0.50",
+			wantFloor: "$0.50",
+			fallback:  true,
+		},
+		{
+			name:      "provider errors fall back to the heuristic",
+			reply:     func(CompletionRequest) (string, error) { return "", errors.New("rate limited") },
+			idea:      strings.Repeat("long request ", 10),
+			wantPrice: "$1.00",
+			wantFloor: "$0.50",
+			fallback:  true,
+		},
+		{
+			name:      "no LLM uses the heuristic",
+			noLLM:     true,
+			idea:      strings.Repeat("x", 60),
+			wantPrice: "$0.75",
+			wantFloor: "$0.50",
+			fallback:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if !tt.noLLM {
+				config.LLM = &Stub{Reply: tt.reply}
+			}
+			config.History = tt.history
+			idea := tt.idea
+			if idea == "" {
+				idea = "a small script"
+			}
+
+			est, err := New(config).EstimatePrice(context.Background(), idea)
+			if err != nil {
+				t.Fatalf("EstimatePrice failed: %v", err)
+			}
+			if got := est.Price.String(); got != tt.wantPrice {
+				t.Errorf("price = %s, want %s", got, tt.wantPrice)
+			}
+			if got := est.Floor.String(); got != tt.wantFloor {
+				t.Errorf("floor = %s, want %s", got, tt.wantFloor)
+			}
+			if est.Floor.Cmp(est.Price) > 0 {
+				t.Errorf("floor %s is above the price %s", est.Floor, est.Price)
+			}
+			if tt.clamped == "" && est.Clamped != "" || !strings.Contains(est.Clamped, tt.clamped) {
+				t.Errorf("clamped = %q, want %q", est.Clamped, tt.clamped)
+			}
+			if est.Fallback != tt.fallback {
+				t.Errorf("fallback = %v, want %v", est.Fallback, tt.fallback)
+			}
+		})
+	}
+}
+
+func TestEstimatePriceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stub := &Stub{Reply: func(CompletionRequest) (string, error) { return "", context.Canceled }}
+
+	if _, err := New(Config{LLM: stub}).EstimatePrice(ctx, "a small script"); !errors.Is(err, context.Canceled) {
+		t.Errorf("EstimatePrice = %v, want context.Canceled", err)
+	}
+}
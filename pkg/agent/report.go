@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// ReportPeriods are the periods profit-and-loss reports cover
+var ReportPeriods = []Period{PeriodDay, PeriodWeek, PeriodMonth}
+
+// ParseReportPeriod parses a report period name
+func ParseReportPeriod(s string) (Period, error) {
+	for _, p := range ReportPeriods {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown report period %q, use day, week or month", s)
+}
+
+// ReportLine is the USD total and number of transactions of one service
+// or spend category
+type ReportLine struct {
+	Name   string
+	Amount money.Money
+	Count  int
+}
+
+// Report is a profit-and-loss statement over one calendar period.
+// All amounts are in USD at the value when each transaction was recorded.
+type Report struct {
+	Period Period
+	Start  time.Time
+	End    time.Time // end of the period, or when the report was made if earlier
+
+	Revenue          money.Money
+	RevenueByService []ReportLine
+	Spent            money.Money
+	SpentByCategory  []ReportLine
+	Refunded         money.Money
+	Net              money.Money // revenue less spends and refunds
+
+	Earnings int
+	Spends   int
+	Refunds  int
+	// Unvalued counts transactions left out of the totals because their
+	// USD value is unknown
+	Unvalued int
+}
+
+// AverageSale returns the mean earning, or zero if there were none
+func (r Report) AverageSale() money.Money {
+	return average(r.Revenue, r.Earnings)
+}
+
+// AverageSpend returns the mean spend, or zero if there were none
+func (r Report) AverageSpend() money.Money {
+	return average(r.Spent, r.Spends)
+}
+
+func average(total money.Money, count int) money.Money {
+	if count == 0 {
+		return money.New(money.Zero, money.USD)
+	}
+	return money.New(total.Amount.Div(money.NewFromInt(int64(count))).Round(2), money.USD)
+}
+
+// Bounds returns when the calendar period containing t starts and ends in
+// the agent's time zone
+func (a *Agent) Bounds(p Period, t time.Time) (time.Time, time.Time) {
+	return p.bounds(t, a.config.location())
+}
+
+// Report builds the profit-and-loss report for the calendar period containing
+// t from the replayed ledger. Only approved spends count; refunds are
+// reported apart from spends, as they are for the budget.
+func (a *Agent) Report(p Period, t time.Time) Report {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+
+	zero := money.New(money.Zero, money.USD)
+	r := Report{Period: p, Revenue: zero, Spent: zero, Refunded: zero}
+	r.Start, r.End = p.bounds(t, a.config.location())
+	if now := time.Now(); now.Before(r.End) {
+		r.End = now
+	}
+
+	services := make(map[string]*ReportLine)
+	categories := make(map[string]*ReportLine)
+	add := func(lines map[string]*ReportLine, name string, usd money.Money) {
+		l, ok := lines[name]
+		if !ok {
+			l = &ReportLine{Name: name, Amount: zero}
+			lines[name] = l
+		}
+		l.Amount = l.Amount.Add(usd)
+		l.Count++
+	}
+
+	for _, tx := range a.transactions {
+		if tx.Timestamp.Before(r.Start) || !tx.Timestamp.Before(r.End) {
+			continue
+		}
+		if tx.Type == "spend" && !tx.Approved {
+			continue
+		}
+		usd, ok := tx.USD()
+		if !ok {
+			r.Unvalued++
+			continue
+		}
+
+		switch tx.Type {
+		case "earn":
+			r.Earnings++
+			r.Revenue = r.Revenue.Add(usd)
+			service := tx.Service
+			if service == "" {
+				service = "other"
+			}
+			add(services, service, usd)
+		case "spend":
+			r.Spends++
+			r.Spent = r.Spent.Add(usd)
+			category := tx.Category
+			if category == "" {
+				category = uncategorized
+			}
+			add(categories, category, usd)
+		case "refund":
+			r.Refunds++
+			r.Refunded = r.Refunded.Add(usd)
+		}
+	}
+
+	r.Net = r.Revenue.Sub(r.Spent).Sub(r.Refunded)
+	r.RevenueByService = sortedLines(services)
+	r.SpentByCategory = sortedLines(categories)
+	return r
+}
+
+// sortedLines orders lines by amount, largest first
+func sortedLines(lines map[string]*ReportLine) []ReportLine {
+	sorted := make([]ReportLine, 0, len(lines))
+	for _, l := range lines {
+		sorted = append(sorted, *l)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Amount.Cmp(sorted[j].Amount); c != 0 {
+			return c > 0
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
@@ -0,0 +1,94 @@
+package agent
+
+import "testing"
+
+func TestSchemaValidate(t *testing.T) {
+	schema := MustParseSchema(`{
+		"type": "object",
+		"required": ["name", "score"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 5},
+			"score": {"type": "integer", "minimum": 1, "maximum": 5},
+			"cost": {"type": "number", "minimum": 0},
+			"mood": {"type": "string", "enum": ["good", "bad"]},
+			"ok": {"type": "boolean"},
+			"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}}
+		}
+	}`)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"minimal", `{"name": "a", "score": 3}`, false},
+		{"every property", `{"name": "abcde", "score": 5, "cost": 0.25, "mood": "good", "ok": true, "tags": ["x", "y"]}`, false},
+		{"multibyte length counts runes", `{"name": "ééééé", "score": 1}`, false},
+		{"surrounding whitespace", " \n{\"name\": \"a\", \"score\": 1}\n ", false},
+		{"not JSON", `name: a`, true},
+		{"empty", ``, true},
+		{"trailing data", `{"name": "a", "score": 1} {}`, true},
+		{"not an object", `["a", 1]`, true},
+		{"missing required", `{"name": "a"}`, true},
+		{"unexpected property", `{"name": "a", "score": 1, "extra": 1}`, true},
+		{"string too short", `{"name": "", "score": 1}`, true},
+		{"string too long", `{"name": "abcdef", "score": 1}`, true},
+		{"wrong type", `{"name": 1, "score": 1}`, true},
+		{"integer with fraction", `{"name": "a", "score": 2.5}`, true},
+		{"integer with exponent", `{"name": "a", "score": 1e0}`, true},
+		{"integer as string", `{"name": "a", "score": "3"}`, true},
+		{"below minimum", `{"name": "a", "score": 0}`, true},
+		{"above maximum", `{"name": "a", "score": 6}`, true},
+		{"negative number", `{"name": "a", "score": 1, "cost": -0.01}`, true},
+		{"not in enum", `{"name": "a", "score": 1, "mood": "meh"}`, true},
+		{"not a boolean", `{"name": "a", "score": 1, "ok": "true"}`, true},
+		{"too few items", `{"name": "a", "score": 1, "tags": []}`, true},
+		{"too many items", `{"name": "a", "score": 1, "tags": ["x", "y", "z"]}`, true},
+		{"wrong item type", `{"name": "a", "score": 1, "tags": [1]}`, true},
+		{"null", `null`, true},
+	}
+	for _, tt := range tests {
+		err := schema.Validate([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSchemaAllowsAdditionalPropertiesByDefault(t *testing.T) {
+	schema := MustParseSchema(`{"type": "object", "properties": {"a": {"type": "string"}}}`)
+	if err := schema.Validate([]byte(`{"a": "x", "b": 1}`)); err != nil {
+		t.Errorf("Validate = %v, want extra properties allowed", err)
+	}
+	if err := schema.Validate([]byte(`{"a": 1}`)); err == nil {
+		t.Error("Validate accepted a known property of the wrong type")
+	}
+}
+
+func TestSchemaRejectsUnsupportedType(t *testing.T) {
+	schema := MustParseSchema(`{"type": "null"}`)
+	if err := schema.Validate([]byte(`null`)); err == nil {
+		t.Error("Validate accepted an unsupported schema type")
+	}
+}
+
+func TestEstimateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"complexity": 2, "compute_cost_usd": 0.05, "suggested_price_usd": 1.5, "reasoning": "Short script."}`, false},
+		{"complexity out of range", `{"complexity": 9, "compute_cost_usd": 0.05, "suggested_price_usd": 1.5, "reasoning": "x"}`, true},
+		{"negative price", `{"complexity": 2, "compute_cost_usd": 0.05, "suggested_price_usd": -1, "reasoning": "x"}`, true},
+		{"empty reasoning", `{"complexity": 2, "compute_cost_usd": 0.05, "suggested_price_usd": 1, "reasoning": ""}`, true},
+		{"missing cost", `{"complexity": 2, "suggested_price_usd": 1, "reasoning": "x"}`, true},
+	}
+	for _, tt := range tests {
+		err := estimateSchema.Validate([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
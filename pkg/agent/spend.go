@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/money"
+)
+
+var (
+	// ErrNotReserved is returned when a spend's payment was already settled
+	ErrNotReserved = errors.New("spend is not reserved")
+	// ErrUnknownPayee is returned for spends to an address not in the address book
+	ErrUnknownPayee = errors.New("payee is not in the address book")
+	// ErrOverLimit is returned by Reserve for spends the limits don't allow;
+	// they can still be sent to an admin with RequestApproval
+	ErrOverLimit = errors.New("over the spending limits")
+)
+
+// AddressBook is the allowlist of addresses the agent may pay
+type AddressBook interface {
+	// Approved reports whether address belongs to an admin-approved payee
+	// on network
+	Approved(address, network string) bool
+}
+
+// checkPayee refuses addresses that are not in the address book for the
+// network amount is paid on. EVM and TRON addresses look the same on every
+// chain of their kind, so a payee approved on one chain is not on another.
+func (a *Agent) checkPayee(payee string, amount money.Money) error {
+	network := money.Network(amount.Currency)
+	if network == "" {
+		return fmt.Errorf("%w: no known network pays out %s", ErrUnknownPayee, amount.Currency)
+	}
+	if a.config.Payees == nil || !a.config.Payees.Approved(payee, network) {
+		return fmt.Errorf("%w: %q on %s", ErrUnknownPayee, payee, network)
+	}
+	return nil
+}
+
+// Reserve holds budget for a spend to payee before its payment is sent.
+// The reservation counts against the limits until it is committed once the
+// payment went out, or rolled back if it did not. The limits are checked
+// under the same lock that records the reservation, so concurrent spends
+// cannot both take the last of a budget; the loser gets ErrOverLimit.
+func (a *Agent) Reserve(category string, amount money.Money, payee, description string) (*Transaction, error) {
+	if err := a.checkPayee(payee, amount); err != nil {
+		return nil, fmt.Errorf("cannot spend %s: %w", amount, err)
+	}
+
+	a.spendingMutex.Lock()
+	defer a.spendingMutex.Unlock()
+
+	if reason := a.checkLimits(category, amount); reason != "" {
+		return nil, fmt.Errorf("cannot spend %s: %w: %s", amount, ErrOverLimit, reason)
+	}
+
+	tx := Transaction{
+		ID:          generateID(),
+		Type:        "spend",
+		Category:    category,
+		Payee:       payee,
+		Amount:      amount,
+		Description: description,
+		Timestamp:   time.Now(),
+		Status:      StatusReserved,
+		DecidedBy:   "policy",
+	}
+	// Without a durable reservation a crash could resend or forget the payment
+	if err := a.record(tx); err != nil {
+		return nil, fmt.Errorf("cannot spend %s: %w", amount, err)
+	}
+
+	log.Info("🔒 Budget reserved",
+		"transaction", tx.ID,
+		"amount", amount,
+		"category", category,
+		"payee", payee)
+	return &tx, nil
+}
+
+// Commit marks a reserved spend paid
+func (a *Agent) Commit(txID string) (*Transaction, error) {
+	tx, err := a.transition(txID, StatusReserved, ErrNotReserved, func(tx *Transaction) {
+		tx.Status = StatusApproved
+		tx.Approved = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("💸 Agent spent money",
+		"transaction", tx.ID,
+		"amount", tx.Amount,
+		"category", tx.Category,
+		"description", tx.Description)
+	return tx, nil
+}
+
+// Rollback releases the budget of a reserved spend whose payment was not sent
+func (a *Agent) Rollback(txID string, cause error) (*Transaction, error) {
+	tx, err := a.transition(txID, StatusReserved, ErrNotReserved, func(tx *Transaction) {
+		tx.Status = StatusFailed
+		tx.Error = cause.Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Warn("🔓 Budget reservation released", "transaction", tx.ID, "amount", tx.Amount, "error", cause)
+	return tx, nil
+}
+
+// Reservations returns spends whose payment has not been settled yet
+func (a *Agent) Reservations() []Transaction {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+
+	var reserved []Transaction
+	for _, tx := range a.transactions {
+		if tx.Status == StatusReserved {
+			reserved = append(reserved, tx)
+		}
+	}
+	return reserved
+}
+
+// Transaction returns the transaction with txID
+func (a *Agent) Transaction(txID string) (*Transaction, bool) {
+	a.spendingMutex.RLock()
+	defer a.spendingMutex.RUnlock()
+
+	i, ok := a.index[txID]
+	if !ok {
+		return nil, false
+	}
+	tx := a.transactions[i]
+	return &tx, true
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"clawclack/pkg/money"
+)
+
+type allowAll struct{}
+
+func (allowAll) Approved(address, network string) bool { return true }
+
+func TestReserveOverLimit(t *testing.T) {
+	a := New(Config{
+		Limits: Limits{SpendingLimitUSD: money.Dollars("10"), DailyBudgetUSD: money.Dollars("10")},
+		Payees: allowAll{},
+	})
+
+	if _, err := a.Reserve("", money.MustParse("11", "USDT"), "TPayee", "too big"); !errors.Is(err, ErrOverLimit) {
+		t.Errorf("Reserve over the per-transaction limit = %v, want ErrOverLimit", err)
+	}
+
+	// Concurrent spends that each fit cannot share the last of the budget
+	var wg sync.WaitGroup
+	results := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.Reserve("", money.MustParse("4", "USDT"), "TPayee", "race")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	reserved, over := 0, 0
+	for err := range results {
+		switch {
+		case err == nil:
+			reserved++
+		case errors.Is(err, ErrOverLimit):
+			over++
+		default:
+			t.Errorf("Reserve = %v, want ErrOverLimit", err)
+		}
+	}
+	if reserved != 2 || over != 2 {
+		t.Errorf("%d reserved and %d over the limit, want 2 and 2", reserved, over)
+	}
+}
+
+func TestReserveUnknownPayee(t *testing.T) {
+	a := New(Config{Limits: Limits{SpendingLimitUSD: money.Dollars("10")}})
+	_, err := a.Reserve("", money.MustParse("1", "USDT"), "TPayee", "no address book")
+	if !errors.Is(err, ErrUnknownPayee) || errors.Is(err, ErrOverLimit) {
+		t.Errorf("Reserve = %v, want ErrUnknownPayee only", err)
+	}
+}
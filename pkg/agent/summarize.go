@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// summarySchema is what the LLM must answer a summary request with
+var summarySchema = MustParseSchema(`{
+	"type": "object",
+	"required": ["summary", "key_points"],
+	"additionalProperties": false,
+	"properties": {
+		"summary": {"type": "string", "minLength": 1, "maxLength": 1200, "description": "two to four sentences on what the article says"},
+		"key_points": {
+			"type": "array", "minItems": 1, "maxItems": 7,
+			"items": {"type": "string", "minLength": 1, "maxLength": 300},
+			"description": "the most important facts or arguments, one sentence each"
+		}
+	}
+}`)
+
+// Summary condenses an article
+type Summary struct {
+	Summary   string   `json:"summary"`
+	KeyPoints []string `json:"key_points"`
+}
+
+// Summarize condenses a document that was split into chunks. Documents of
+// more than one chunk are first turned into notes chunk by chunk, so no
+// single request exceeds the model's context, and the notes are summarized.
+func (a *Agent) Summarize(ctx context.Context, title string, chunks []string) (*Summary, error) {
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("nothing to summarize")
+	}
+
+	about := "the article"
+	if title != "" {
+		about = fmt.Sprintf("the article %q", title)
+	}
+
+	text := chunks[0]
+	if len(chunks) > 1 {
+		notes := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			completion, err := a.Complete(ctx, CompletionRequest{
+				Messages: []Message{
+					{Role: RoleSystem, Content: fmt.Sprintf("You take notes for a summary of %s. "+
+						"This is part %d of %d. Write concise notes of its key facts, figures and arguments. "+
+						"Do not add anything that is not in the text.", about, i+1, len(chunks))},
+					{Role: RoleUser, Content: chunk},
+				},
+				MaxTokens: 500,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to summarize part %d of %d: %w", i+1, len(chunks), err)
+			}
+			notes = append(notes, completion.Content)
+		}
+		text = strings.Join(notes, "\n\n")
+	}
+
+	var summary Summary
+	err := a.CompleteJSON(ctx, CompletionRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: fmt.Sprintf("You summarize %s for a chat room. "+
+				"Be accurate and neutral, and do not add anything that is not in the text.", about)},
+			{Role: RoleUser, Content: text},
+		},
+		MaxTokens: 800,
+	}, summarySchema, &summary)
+	if err != nil {
+		return nil, fmt.Errorf("summary: %w", err)
+	}
+	return &summary, nil
+}
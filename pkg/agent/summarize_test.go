@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testSchema = MustParseSchema(`{
+	"type": "object",
+	"required": ["answer"],
+	"additionalProperties": false,
+	"properties": {"answer": {"type": "integer", "minimum": 1}}
+}`)
+
+func TestCompleteJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []string
+		want    int
+		wantErr bool
+		calls   int
+	}{
+		{"valid", []string{`{"answer": 42}`}, 42, false, 1},
+		{"code fence stripped", []string{"```json\n{\"answer\": 7}\n```"}, 7, false, 1},
+		{"retried after a schema error", []string{`{"answer": 0}`, `{"answer": 3}`}, 3, false, 2},
+		{"retried after prose", []string{"The answer is three.", `{"answer": 3}`}, 3, false, 2},
+		{"gives up after the retry", []string{`{"answer": "three"}`, `{"answer": 3, "extra": true}`, `{"answer": 3}`}, 0, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			stub := &Stub{Reply: func(CompletionRequest) (string, error) {
+				calls++
+				return tt.replies[calls-1], nil
+			}}
+
+			var v struct {
+				Answer int `json:"answer"`
+			}
+			err := New(Config{LLM: stub}).CompleteJSON(context.Background(), CompletionRequest{
+				Messages: []Message{{Role: RoleUser, Content: "What is the answer?"}},
+			}, testSchema, &v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompleteJSON = %v, want error %v", err, tt.wantErr)
+			}
+			if v.Answer != tt.want || calls != tt.calls {
+				t.Errorf("answer %d after %d calls, want %d after %d", v.Answer, calls, tt.want, tt.calls)
+			}
+
+			requests := stub.Requests()
+			if !requests[0].JSON || !strings.Contains(requests[0].Messages[1].Content, `"answer"`) {
+				t.Errorf("first request %+v does not ask for JSON with the schema", requests[0])
+			}
+			if len(requests) > 1 {
+				// The rejected reply goes back with the reason
+				retry := requests[1].Messages
+				if len(retry) != 4 || retry[2].Role != RoleAssistant || !strings.Contains(retry[3].Content, "invalid") {
+					t.Errorf("retry messages = %+v, want the rejected reply and the error", retry)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stub := &Stub{Reply: func(req CompletionRequest) (string, error) {
+		if !req.JSON {
+			return "notes on " + lastUserMessage(req.Messages), nil
+		}
+		return `{"summary": "It says things.", "key_points": ["` + strings.ReplaceAll(lastUserMessage(req.Messages), "\n\n", " / ") + `"]}`, nil
+	}}
+	a := New(Config{LLM: stub})
+
+	summary, err := a.Summarize(context.Background(), "Title", []string{"only part"})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary.Summary != "It says things." || summary.KeyPoints[0] != "only part" {
+		t.Errorf("Summarize = %+v, want a summary of the single chunk", summary)
+	}
+	if len(stub.Requests()) != 1 {
+		t.Errorf("a single chunk took %d requests, want 1", len(stub.Requests()))
+	}
+
+	// Longer documents are summarized from notes on each chunk
+	summary, err = a.Summarize(context.Background(), "", []string{"part one", "part two"})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if want := "notes on part one / notes on part two"; summary.KeyPoints[0] != want {
+		t.Errorf("summarized %q, want %q", summary.KeyPoints[0], want)
+	}
+	if len(stub.Requests()) != 4 {
+		t.Errorf("two chunks took %d requests in total, want 3 more", len(stub.Requests())-1)
+	}
+
+	if _, err := a.Summarize(context.Background(), "", nil); err == nil {
+		t.Error("Summarize of nothing succeeded")
+	}
+	failing := New(Config{LLM: &Stub{Reply: func(CompletionRequest) (string, error) { return "", errors.New("overloaded") }}})
+	if _, err := failing.Summarize(context.Background(), "", []string{"a", "b"}); err == nil || !strings.Contains(err.Error(), "part 1 of 2") {
+		t.Errorf("Summarize = %v, want the failed part", err)
+	}
+}
@@ -0,0 +1,80 @@
+package article
+
+import "strings"
+
+// Chunk splits text into pieces of at most maxChars bytes for the LLM.
+// Pieces break between paragraphs where possible, then between sentences,
+// and only cut words when a single sentence is longer than maxChars.
+func Chunk(text string, maxChars int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxChars <= 0 || len(text) <= maxChars {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		current.Reset()
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && current.Len()+len(sep)+len(piece) > maxChars {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		if len(para) <= maxChars {
+			add(para, "\n\n")
+			continue
+		}
+		for _, sentence := range sentences(para) {
+			for len(sentence) > maxChars {
+				cut := cutAt(sentence, maxChars)
+				add(sentence[:cut], " ")
+				flush()
+				sentence = strings.TrimSpace(sentence[cut:])
+			}
+			add(sentence, " ")
+		}
+	}
+	flush()
+	return chunks
+}
+
+// sentences splits a paragraph after each ". ", "! " or "? "
+func sentences(para string) []string {
+	var out []string
+	start := 0
+	for i := 0; i+1 < len(para); i++ {
+		if (para[i] == '.' || para[i] == '!' || para[i] == '?') && para[i+1] == ' ' {
+			out = append(out, para[start:i+1])
+			start = i + 2
+		}
+	}
+	if start < len(para) {
+		out = append(out, para[start:])
+	}
+	return out
+}
+
+// cutAt returns where to cut s to at most max bytes: the last space before
+// max, or max itself moved back to a UTF-8 boundary
+func cutAt(s string, max int) int {
+	if i := strings.LastIndexByte(s[:max], ' '); i > 0 {
+		return i
+	}
+	for max > 0 && s[max]&0xC0 == 0x80 {
+		max--
+	}
+	return max
+}
@@ -0,0 +1,64 @@
+package article
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     []string
+	}{
+		{"empty", "  \n ", 10, nil},
+		{"fits", " short text ", 100, []string{"short text"}},
+		{"no limit", strings.Repeat("a", 50), 0, []string{strings.Repeat("a", 50)}},
+		{
+			"between paragraphs",
+			"First paragraph.\n\nSecond one.\n\nThird paragraph here.",
+			30,
+			[]string{"First paragraph.\n\nSecond one.", "Third paragraph here."},
+		},
+		{
+			"between sentences",
+			"One sentence. Another sentence! A third one? And the last.",
+			30,
+			[]string{"One sentence.", "Another sentence! A third one?", "And the last."},
+		},
+		{
+			"long words are cut",
+			strings.Repeat("x", 25),
+			10,
+			[]string{"xxxxxxxxxx", "xxxxxxxxxx", "xxxxx"},
+		},
+	}
+	for _, tt := range tests {
+		got := Chunk(tt.text, tt.maxChars)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("%s: Chunk = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChunkLimits(t *testing.T) {
+	text := strings.Repeat("Ünïcödé wörds fill this sentence to the brim. ", 40) + "\n\n" +
+		strings.Repeat("日本語のテキスト", 30)
+	chunks := Chunk(text, 64)
+	for i, chunk := range chunks {
+		if len(chunk) > 64 {
+			t.Errorf("chunk %d is %d bytes, want at most 64", i, len(chunk))
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d splits a character: %q", i, chunk)
+		}
+	}
+
+	// Nothing but whitespace is lost between chunks
+	joined := strings.Join(strings.Fields(strings.Join(chunks, "")), "")
+	if want := strings.Join(strings.Fields(text), ""); joined != want {
+		t.Errorf("chunks lost text: got %d characters, want %d", len(joined), len(want))
+	}
+}
@@ -0,0 +1,288 @@
+package article
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minArticleChars is the text a candidate needs before the whole body is
+// used instead
+const minArticleChars = 250
+
+// ErrNoContent is returned for pages without readable text
+var ErrNoContent = errors.New("no readable text found on the page")
+
+var (
+	// Class and id hints, as in Mozilla's Readability
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text|blog`)
+	negativeHint = regexp.MustCompile(`(?i)comment|combx|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|social|tags|tool|widget|banner|cookie|newsletter|subscribe|nav|menu|breadcrumb|popup|modal|advert`)
+	spaces       = regexp.MustCompile(`\s+`)
+)
+
+// skipped elements never hold article text
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Svg: true, atom.Canvas: true, atom.Form: true, atom.Button: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Select: true, atom.Textarea: true, atom.Template: true, atom.Object: true,
+}
+
+// blocks become paragraphs of the extracted text
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Blockquote: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Td: true, atom.Dd: true, atom.Dt: true, atom.Figcaption: true,
+}
+
+// Article is the readable part of a web page
+type Article struct {
+	URL   string
+	Title string
+	Text  string // paragraphs separated by blank lines
+}
+
+// Extract finds the main article of an HTML page, readability style:
+// paragraphs score the elements around them, boilerplate is skipped, and
+// the best scoring element's text is kept
+func Extract(r io.Reader, pageURL string) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Article{URL: pageURL, Title: title(doc)}
+
+	scores := make(map[*html.Node]float64)
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && (skipped[n.DataAtom] || unlikely(n)) {
+			return false
+		}
+		if n.Type != html.ElementNode || (n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td) {
+			return true
+		}
+
+		text := textOf(n)
+		if len(text) < 25 {
+			return true
+		}
+		// One point per paragraph, per comma and per 100 characters, up to 3
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		if parent := n.Parent; parent != nil {
+			scores[parent] += score
+			if grand := parent.Parent; grand != nil {
+				scores[grand] += score / 2
+			}
+		}
+		return true
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		score = (score + classWeight(n)) * (1 - linkDensity(n))
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+
+	if best != nil {
+		a.Text = paragraphs(best)
+	}
+	if len(a.Text) < minArticleChars {
+		// Pages without a clear article, e.g. plain documents
+		if body := find(doc, atom.Body); body != nil {
+			if text := paragraphs(body); len(text) > len(a.Text) {
+				a.Text = text
+			}
+			// Text straight in divs has no blocks to split it by
+			if a.Text == "" {
+				a.Text = textOf(body)
+			}
+		}
+	}
+	if strings.TrimSpace(a.Text) == "" {
+		return nil, ErrNoContent
+	}
+	return a, nil
+}
+
+// FromText wraps a plain text document
+func FromText(text, pageURL string) (*Article, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrNoContent
+	}
+	return &Article{URL: pageURL, Text: text}, nil
+}
+
+// title prefers og:title, which usually lacks the site name, over <title>
+func title(doc *html.Node) string {
+	var og, plain, h1 string
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		switch n.DataAtom {
+		case atom.Meta:
+			if attr(n, "property") == "og:title" && og == "" {
+				og = collapse(attr(n, "content"))
+			}
+		case atom.Title:
+			if plain == "" {
+				plain = textOf(n)
+			}
+		case atom.H1:
+			if h1 == "" {
+				h1 = textOf(n)
+			}
+		}
+		return true
+	})
+	for _, t := range []string{og, plain, h1} {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// paragraphs returns the text of the block elements under n, one per paragraph
+func paragraphs(n *html.Node) string {
+	var parts []string
+	walk(n, func(c *html.Node) bool {
+		if c.Type != html.ElementNode {
+			return true
+		}
+		if c != n && (skipped[c.DataAtom] || unlikely(c)) {
+			return false
+		}
+		if !blocks[c.DataAtom] {
+			return true
+		}
+		text := textOf(c)
+		if text == "" {
+			return false
+		}
+		switch c.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			text = "## " + text
+		case atom.Li:
+			text = "- " + text
+		}
+		parts = append(parts, text)
+		// The text of nested blocks is already included
+		return false
+	})
+	return strings.Join(parts, "\n\n")
+}
+
+// unlikely reports whether an element looks like boilerplate by its class,
+// id or role
+func unlikely(n *html.Node) bool {
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	switch attr(n, "role") {
+	case "navigation", "banner", "complementary", "contentinfo", "dialog":
+		return true
+	}
+	return negativeHint.MatchString(hints) && !positiveHint.MatchString(hints)
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		weight += 25
+	}
+	for _, hints := range []string{attr(n, "class"), attr(n, "id")} {
+		if hints == "" {
+			continue
+		}
+		if negativeHint.MatchString(hints) {
+			weight -= 25
+		}
+		if positiveHint.MatchString(hints) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of n's text that sits inside links
+func linkDensity(n *html.Node) float64 {
+	total := len(textOf(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += len(textOf(c))
+			return false
+		}
+		return true
+	})
+	return float64(linked) / float64(total)
+}
+
+// textOf returns the visible text under n with whitespace collapsed
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && skipped[c.DataAtom] {
+			return false
+		}
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return collapse(b.String())
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s, " "))
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if c.Type == html.ElementNode && c.DataAtom == a {
+			found = c
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// walk visits n and its descendants depth first; fn returns false to skip
+// a node's children
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
@@ -0,0 +1,102 @@
+package article
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const newsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Rates rise again | Example News</title>
+	<meta property="og:title" content="Rates  rise again">
+	<script>var tracking = "Do not read this, it is a script, with commas, and more commas";</script>
+</head>
+<body>
+	<nav class="menu"><a href="/">Home</a> <a href="/world">World news, politics, sport, weather</a></nav>
+	<div id="sidebar" class="sidebar">
+		<p>Trending: ten things you will not believe, number seven, eight and nine will shock you.</p>
+	</div>
+	<article class="story">
+		<h1>Rates rise again</h1>
+		<p>The central bank raised its main rate by a quarter point on Thursday, the fifth rise this year, citing persistent inflation.</p>
+		<p>Economists had expected the move, although some warned that higher borrowing costs would weigh on growth, hiring and housing.</p>
+		<ul><li>Mortgage rates are likely to follow.</li></ul>
+		<p>The bank said further rises were possible if prices kept climbing faster than its target.</p>
+	</article>
+	<footer><p>Copyright Example News, all rights reserved, terms, privacy, cookies, contact us.</p></footer>
+</body>
+</html>`
+
+func TestExtract(t *testing.T) {
+	a, err := Extract(strings.NewReader(newsPage), "https://news.example.org/rates")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if a.Title != "Rates rise again" || a.URL != "https://news.example.org/rates" {
+		t.Errorf("Extract = %q at %s, want the og:title and page URL", a.Title, a.URL)
+	}
+
+	paragraphs := strings.Split(a.Text, "\n\n")
+	if len(paragraphs) != 5 || paragraphs[0] != "## Rates rise again" || paragraphs[3] != "- Mortgage rates are likely to follow." {
+		t.Errorf("Extract text = %q, want the heading, three paragraphs and the list item", paragraphs)
+	}
+	for _, boilerplate := range []string{"tracking", "Trending", "World news", "Copyright"} {
+		if strings.Contains(a.Text, boilerplate) {
+			t.Errorf("Extract kept %q:\n%s", boilerplate, a.Text)
+		}
+	}
+}
+
+func TestExtractFallsBackToBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		title string
+		want  string
+	}{
+		{
+			"short paragraphs",
+			`<html><head><title>Notes</title></head><body><p>Just a short note.</p><p>And another.</p></body></html>`,
+			"Notes",
+			"Just a short note.\n\nAnd another.",
+		},
+		{
+			"no blocks at all",
+			`<html><body><div>Plain text <span>without</span> any blocks.</div></body></html>`,
+			"",
+			"Plain text without any blocks.",
+		},
+	}
+	for _, tt := range tests {
+		a, err := Extract(strings.NewReader(tt.page), "https://example.org/")
+		if err != nil {
+			t.Errorf("%s: Extract failed: %v", tt.name, err)
+			continue
+		}
+		if a.Title != tt.title || a.Text != tt.want {
+			t.Errorf("%s: Extract = %q, %q, want %q, %q", tt.name, a.Title, a.Text, tt.title, tt.want)
+		}
+	}
+}
+
+func TestExtractNoContent(t *testing.T) {
+	pages := []string{
+		``,
+		`<html><head><title>Empty</title></head><body></body></html>`,
+		`<html><body><script>render()</script><nav><a href="/">Home</a></nav></body></html>`,
+	}
+	for _, page := range pages {
+		if _, err := Extract(strings.NewReader(page), ""); !errors.Is(err, ErrNoContent) {
+			t.Errorf("Extract(%q) = %v, want ErrNoContent", page, err)
+		}
+	}
+
+	if _, err := FromText(" \n\t", ""); !errors.Is(err, ErrNoContent) {
+		t.Errorf("FromText of whitespace = %v, want ErrNoContent", err)
+	}
+	if a, err := FromText("\n plain text \n", "https://example.org/a.txt"); err != nil || a.Text != "plain text" {
+		t.Errorf("FromText = %v, %v, want the trimmed text", a, err)
+	}
+}
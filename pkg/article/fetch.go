@@ -0,0 +1,59 @@
+package article
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/fetch"
+)
+
+// Fetcher downloads pages and extracts their article. Size, time and
+// destination limits are those of its fetch.Client.
+type Fetcher struct {
+	Client *fetch.Client
+}
+
+// NewFetcher creates a fetcher that downloads through client
+func NewFetcher(client *fetch.Client) *Fetcher {
+	return &Fetcher{Client: client}
+}
+
+// Check reports whether the page at rawURL may be fetched at all
+func (f *Fetcher) Check(ctx context.Context, rawURL string) error {
+	return f.Client.Check(ctx, rawURL)
+}
+
+// Fetch downloads a page and extracts its article
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Article, error) {
+	resp, err := f.Client.Get(ctx, rawURL, http.Header{
+		"Accept": {"text/html,application/xhtml+xml,text/plain;q=0.9"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "text/plain", "":
+	default:
+		return nil, fmt.Errorf("cannot summarize %s content", mediaType)
+	}
+	if resp.Truncated {
+		log.Warn("Page cut off at the size limit", "url", rawURL, "bytes", len(resp.Body))
+	}
+
+	// The final URL after redirects is the one worth linking
+	pageURL := resp.URL.String()
+	if mediaType == "text/plain" {
+		return FromText(string(resp.Body), pageURL)
+	}
+	return Extract(bytes.NewReader(resp.Body), pageURL)
+}
@@ -0,0 +1,200 @@
+package credits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"clawclack/pkg/money"
+)
+
+var (
+	balancesBucket = []byte("balances")
+	entriesBucket  = []byte("entries")
+)
+
+// ErrInsufficientFunds is returned when a debit exceeds the user's balance
+var ErrInsufficientFunds = errors.New("insufficient credits")
+
+// Entry kinds
+const (
+	KindTopUp  = "topup"  // paid in through an invoice
+	KindDebit  = "debit"  // spent on a service
+	KindCredit = "credit" // overpayments and refunds of credit-paid orders
+)
+
+// Entry is one movement on a user's credit account
+type Entry struct {
+	ID          string        `json:"id"`
+	Kind        string        `json:"kind"`
+	Amount      money.Decimal `json:"amount"`  // always positive, in USD
+	Balance     money.Decimal `json:"balance"` // balance after this entry
+	Description string        `json:"description"`
+	OrderID     string        `json:"order_id,omitempty"`
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// Store keeps prepaid USD credit balances per Matrix user
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the credit database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credit store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{balancesBucket, entriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Balance returns the user's credit balance in USD
+func (s *Store) Balance(userID string) (money.Money, error) {
+	var balance money.Decimal
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		balance, err = getBalance(tx, userID)
+		return err
+	})
+	return money.New(balance, money.USD), err
+}
+
+// Credit adds amount to the user's balance
+func (s *Store) Credit(userID, kind string, amount money.Money, description, orderID string) (*Entry, error) {
+	return s.apply(userID, kind, amount, description, orderID)
+}
+
+// Debit takes amount from the user's balance, failing with
+// ErrInsufficientFunds rather than going negative
+func (s *Store) Debit(userID string, amount money.Money, description, orderID string) (*Entry, error) {
+	return s.apply(userID, KindDebit, amount, description, orderID)
+}
+
+func (s *Store) apply(userID, kind string, amount money.Money, description, orderID string) (*Entry, error) {
+	if amount.Currency != money.USD {
+		return nil, fmt.Errorf("credits are kept in USD, got %s", amount)
+	}
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("credit amount must be positive, got %s", amount)
+	}
+
+	entry := &Entry{
+		ID:          uuid.New().String(),
+		Kind:        kind,
+		Amount:      amount.Amount,
+		Description: description,
+		OrderID:     orderID,
+		Timestamp:   time.Now(),
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		balance, err := getBalance(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if kind == KindDebit {
+			if balance.Cmp(amount.Amount) < 0 {
+				return ErrInsufficientFunds
+			}
+			balance = balance.Sub(amount.Amount)
+		} else {
+			balance = balance.Add(amount.Amount)
+		}
+		entry.Balance = balance
+
+		text, err := balance.MarshalText()
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(balancesBucket).Put([]byte(userID), text); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(entriesBucket).Put(entryKey(userID, entry.Timestamp, entry.ID), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// History returns the user's most recent entries, newest first
+func (s *Store) History(userID string, limit int) ([]*Entry, error) {
+	var result []*Entry
+	prefix := []byte(userID + "|")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(entriesBucket).Cursor()
+
+		// Walk backwards from the end of the user's key range
+		k, v := c.Seek(append(append([]byte{}, prefix...), 0xff))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Prev() {
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("failed to decode credit entry %s: %w", k, err)
+			}
+			result = append(result, &entry)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func getBalance(tx *bolt.Tx, userID string) (money.Decimal, error) {
+	var balance money.Decimal
+	data := tx.Bucket(balancesBucket).Get([]byte(userID))
+	if data == nil {
+		return balance, nil
+	}
+	if err := balance.UnmarshalText(data); err != nil {
+		return balance, fmt.Errorf("failed to decode balance of %s: %w", userID, err)
+	}
+	return balance, nil
+}
+
+// entryKey sorts a user's entries chronologically
+func entryKey(userID string, ts time.Time, entryID string) []byte {
+	return []byte(fmt.Sprintf("%s|%020d|%s", userID, ts.UnixNano(), entryID))
+}
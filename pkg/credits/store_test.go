@@ -0,0 +1,140 @@
+package credits
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"clawclack/pkg/money"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "data", "credits.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestCreditAndDebit(t *testing.T) {
+	store := openTestStore(t)
+	alice := "@alice:example.org"
+
+	if balance, _ := store.Balance(alice); balance.String() != "$0.00" {
+		t.Errorf("new balance = %s, want $0.00", balance)
+	}
+
+	entry, err := store.Credit(alice, KindTopUp, money.Dollars("5"), "Top-up", "o1")
+	if err != nil {
+		t.Fatalf("Credit failed: %v", err)
+	}
+	if entry.Balance.String() != "5" || entry.OrderID != "o1" {
+		t.Errorf("credit entry = %+v, want balance 5 for o1", entry)
+	}
+
+	if _, err := store.Debit(alice, money.Dollars("1.25"), "!code", "o2"); err != nil {
+		t.Fatalf("Debit failed: %v", err)
+	}
+	if _, err := store.Debit(alice, money.Dollars("3.76"), "!image", "o3"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdraft = %v, want ErrInsufficientFunds", err)
+	}
+	if _, err := store.Credit(alice, KindCredit, money.Dollars("0.25"), "Overpayment", "o4"); err != nil {
+		t.Fatalf("Credit failed: %v", err)
+	}
+
+	if balance, _ := store.Balance(alice); balance.String() != "$4.00" {
+		t.Errorf("balance = %s, want $4.00", balance)
+	}
+	if balance, _ := store.Balance("@bob:example.org"); !balance.IsZero() {
+		t.Errorf("bob's balance = %s, want nothing", balance)
+	}
+}
+
+func TestApplyRejectsInvalidAmounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount money.Money
+	}{
+		{"not USD", money.MustParse("1", "USDT")},
+		{"zero", money.Dollars("0")},
+		{"negative", money.Dollars("-1")},
+	}
+	store := openTestStore(t)
+	for _, tt := range tests {
+		if _, err := store.Credit("@alice:example.org", KindTopUp, tt.amount, tt.name, ""); err == nil {
+			t.Errorf("Credit %s succeeded", tt.name)
+		}
+		if _, err := store.Debit("@alice:example.org", tt.amount, tt.name, ""); err == nil {
+			t.Errorf("Debit %s succeeded", tt.name)
+		}
+	}
+	if history, _ := store.History("@alice:example.org", 0); len(history) != 0 {
+		t.Errorf("rejected amounts left %d entries", len(history))
+	}
+}
+
+func TestConcurrentDebitsCannotOverdraw(t *testing.T) {
+	store := openTestStore(t)
+	if _, err := store.Credit("@alice:example.org", KindTopUp, money.Dollars("3"), "Top-up", ""); err != nil {
+		t.Fatalf("Credit failed: %v", err)
+	}
+
+	results := make(chan error, 5)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			_, err := store.Debit("@alice:example.org", money.Dollars("1"), "!code", "")
+			results <- err
+		}()
+	}
+	debited := 0
+	for i := 0; i < cap(results); i++ {
+		switch err := <-results; {
+		case err == nil:
+			debited++
+		case !errors.Is(err, ErrInsufficientFunds):
+			t.Errorf("Debit = %v, want ErrInsufficientFunds", err)
+		}
+	}
+	if debited != 3 {
+		t.Errorf("%d debits went through, want 3", debited)
+	}
+	if balance, _ := store.Balance("@alice:example.org"); !balance.IsZero() {
+		t.Errorf("balance = %s, want $0.00", balance)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	store := openTestStore(t)
+	alice, bob := "@alice:example.org", "@bob:example.org"
+
+	for i := 1; i <= 4; i++ {
+		if _, err := store.Credit(alice, KindTopUp, money.Dollars("1"), fmt.Sprintf("alice %d", i), ""); err != nil {
+			t.Fatalf("Credit failed: %v", err)
+		}
+		if _, err := store.Credit(bob, KindTopUp, money.Dollars("2"), fmt.Sprintf("bob %d", i), ""); err != nil {
+			t.Fatalf("Credit failed: %v", err)
+		}
+	}
+
+	history, err := store.History(alice, 3)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	var got []string
+	for _, e := range history {
+		got = append(got, e.Description)
+	}
+	if fmt.Sprint(got) != "[alice 4 alice 3 alice 2]" {
+		t.Errorf("History(alice, 3) = %v, want her three newest entries", got)
+	}
+
+	if all, _ := store.History(bob, 0); len(all) != 4 || all[0].Balance.String() != "8" {
+		t.Errorf("History(bob, 0) = %d entries, want all 4 ending at a balance of 8", len(all))
+	}
+	if none, _ := store.History("@carol:example.org", 0); len(none) != 0 {
+		t.Errorf("History of a new user = %d entries, want none", len(none))
+	}
+}
@@ -0,0 +1,317 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxBytes     = 2 << 20
+	defaultTimeout      = 20 * time.Second
+	defaultMaxRedirects = 5
+)
+
+var (
+	// ErrBlocked is returned for URLs the fetcher refuses to connect to
+	ErrBlocked = errors.New("destination not allowed")
+
+	// blockedRanges are never fetched on top of loopback, private, link-local,
+	// multicast and unspecified addresses
+	blockedRanges = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+		netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, used by VPNs such as Tailscale
+		netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+		netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+		netip.MustParsePrefix("240.0.0.0/4"),    // reserved, including broadcast
+		netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, can embed private IPv4
+		netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+		netip.MustParsePrefix("2001::/32"),      // Teredo, can embed private IPv4
+		netip.MustParsePrefix("2002::/16"),      // 6to4, can embed private IPv4
+		netip.MustParsePrefix("100::/64"),       // discard-only
+	}
+)
+
+// Options configure a Client; zero values take the defaults
+type Options struct {
+	// Schemes allowed (defaults to http and https)
+	Schemes []string
+	// Ports allowed (defaults to 80 and 443)
+	Ports []int
+	// Blocked ranges on top of the built-in ones, e.g. the VPN subnet
+	Blocked []netip.Prefix
+	// MaxBytes of a response body that are read (defaults to 2 MiB)
+	MaxBytes int64
+	// Timeout for a whole request including redirects (defaults to 20s)
+	Timeout time.Duration
+	// MaxRedirects followed (defaults to 5)
+	MaxRedirects int
+	// UserAgent sent with every request
+	UserAgent string
+}
+
+// Client fetches user-supplied URLs without letting them reach the bot's own
+// network, such as SHKeeper over the VPN. DNS is resolved here, and every
+// address a request or redirect connects to is checked first.
+type Client struct {
+	opts     Options
+	resolver *net.Resolver
+	client   *http.Client
+}
+
+// Response is a fetched page
+type Response struct {
+	URL        *url.URL // after redirects
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Truncated  bool // the body was longer than MaxBytes and was cut off
+}
+
+// New creates a client
+func New(opts Options) *Client {
+	if len(opts.Schemes) == 0 {
+		opts.Schemes = []string{"http", "https"}
+	}
+	if len(opts.Ports) == 0 {
+		opts.Ports = []int{80, 443}
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+
+	c := &Client{opts: opts, resolver: net.DefaultResolver}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Last line of defence: whatever address is about to be connected to
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrBlocked, err)
+			}
+			return c.checkAddr(addrPort.Addr())
+		},
+	}
+	transport := &http.Transport{
+		// Environment proxies would connect on our behalf, unchecked
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return c.dial(ctx, dialer, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: opts.Timeout,
+	}
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			// The new host's addresses are checked again when it is dialled
+			return c.checkURL(req.URL)
+		},
+	}
+	return c
+}
+
+// Check reports whether rawURL may be fetched, resolving its host. Fetching
+// checks again, since DNS answers can change in between.
+func (c *Client) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := c.checkURL(u); err != nil {
+		return err
+	}
+	_, err = c.resolve(ctx, u.Hostname())
+	return err
+}
+
+// Get fetches rawURL, reading at most MaxBytes of the body
+func (c *Client) Get(ctx context.Context, rawURL string, header http.Header) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := c.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.opts.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	truncated := int64(len(body)) > c.opts.MaxBytes
+	if truncated {
+		body = body[:c.opts.MaxBytes]
+	}
+
+	return &Response{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Truncated:  truncated,
+	}, nil
+}
+
+// checkURL enforces the allowed schemes and ports
+func (c *Client) checkURL(u *url.URL) error {
+	if !contains(c.opts.Schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: no host", ErrBlocked)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in URL", ErrBlocked)
+	}
+	port, err := portOf(u)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range c.opts.Ports {
+		if port == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: port %d", ErrBlocked, port)
+}
+
+// dial resolves host itself and connects to the first allowed address,
+// so the connection cannot end up somewhere the check did not see
+func (c *Client) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// resolve looks host up and refuses it if any of its addresses is blocked,
+// so a name cannot mix a public address with an internal one
+func (c *Client) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		if addrs, err = c.resolver.LookupNetIP(ctx, "ip", host); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to resolve %s: no addresses", host)
+		}
+	}
+
+	for _, addr := range addrs {
+		if err := c.checkAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+	return addrs, nil
+}
+
+// checkAddr refuses internal and reserved addresses
+func (c *Client) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.Zone() != "" {
+		return fmt.Errorf("%w: %s is a scoped address", ErrBlocked, addr)
+	}
+	switch {
+	case addr.IsLoopback():
+		return fmt.Errorf("%w: %s is a loopback address", ErrBlocked, addr)
+	case addr.IsPrivate():
+		return fmt.Errorf("%w: %s is a private address", ErrBlocked, addr)
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return fmt.Errorf("%w: %s is a link-local address", ErrBlocked, addr)
+	case addr.IsUnspecified(), addr.IsMulticast(), addr.IsInterfaceLocalMulticast():
+		return fmt.Errorf("%w: %s is not a unicast address", ErrBlocked, addr)
+	}
+	for _, prefix := range blockedRanges {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is in reserved range %s", ErrBlocked, addr, prefix)
+		}
+	}
+	for _, prefix := range c.opts.Blocked {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is in blocked range %s", ErrBlocked, addr, prefix)
+		}
+	}
+	return nil
+}
+
+func portOf(u *url.URL) (int, error) {
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return 0, fmt.Errorf("%w: invalid port %q", ErrBlocked, p)
+		}
+		return port, nil
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return 80, nil
+	case "https":
+		return 443, nil
+	}
+	return 0, fmt.Errorf("%w: no port for scheme %q", ErrBlocked, u.Scheme)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+)
+
+// BudgetAlerts posts a notice to a room the first time spending crosses a
+// threshold of a budget window or of a per-transaction limit
+type BudgetAlerts struct {
+	Client *mautrix.Client
+	Agent  *agent.Agent
+	Room   id.RoomID
+	// Thresholds in percent of each limit, e.g. 50, 80 and 100
+	Thresholds []int
+
+	mu       sync.Mutex
+	notified map[string]time.Time // threshold key -> start of the window it was crossed in
+}
+
+// Prime marks thresholds that are already crossed as notified, so a
+// restart does not repeat the notices of the current windows
+func (b *BudgetAlerts) Prime() {
+	for _, msg := range b.check(nil) {
+		log.Debug("Budget threshold already crossed", "notice", msg)
+	}
+}
+
+// Check posts notices for thresholds crossed since the last check. tx is the
+// spend that was just reserved, for the per-transaction limits; it may be nil.
+func (b *BudgetAlerts) Check(tx *agent.Transaction) {
+	if b == nil || b.Room == "" {
+		return
+	}
+	for _, msg := range b.check(tx) {
+		log.Info("📊 Budget threshold crossed", "notice", msg)
+		Reply(&Context{Client: b.Client, RoomID: b.Room}, msg)
+	}
+}
+
+// check returns a notice for every threshold that is newly crossed
+func (b *BudgetAlerts) check(tx *agent.Transaction) []string {
+	if b == nil || len(b.Thresholds) == 0 {
+		return nil
+	}
+	thresholds := append([]int(nil), b.Thresholds...)
+	sort.Ints(thresholds)
+
+	stats := b.Agent.GetSpendingStats()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.notified == nil {
+		b.notified = make(map[string]time.Time)
+	}
+
+	var notices []string
+	windows := stats.Windows
+	for _, c := range stats.Categories {
+		windows = append(windows, c.Windows...)
+	}
+	for _, w := range windows {
+		// Only the highest newly crossed threshold is worth a message
+		notice := ""
+		for _, pct := range thresholds {
+			key := fmt.Sprintf("%s/%s/%d", w.Category, w.Period, pct)
+			// The rolling window has no fixed start; it re-arms once spending drops
+			start := w.Start
+			if w.Period == agent.PeriodRolling {
+				start = time.Time{}
+			}
+			if b.crossed(key, start, reaches(w.Spent, w.Limit, pct), w.Period == agent.PeriodRolling) {
+				notice = windowNotice(w, pct)
+			}
+		}
+		if notice != "" {
+			notices = append(notices, notice)
+		}
+	}
+
+	if tx == nil {
+		return notices
+	}
+	usd, ok := tx.USD()
+	if !ok {
+		return notices
+	}
+	limit := b.Agent.GetSpendingLimit()
+	for _, c := range stats.Categories {
+		if c.Name == tx.Category && c.Limits.SpendingLimitUSD.Sign() > 0 &&
+			(limit.Sign() == 0 || c.Limits.SpendingLimitUSD.Cmp(limit) < 0) {
+			limit = c.Limits.SpendingLimitUSD
+		}
+	}
+	// Per-transaction notices are sent once per threshold and day
+	day, _ := b.Agent.Bounds(agent.PeriodDay, tx.Timestamp)
+	notice := ""
+	for _, pct := range thresholds {
+		if b.crossed(fmt.Sprintf("transaction/%d", pct), day, reaches(usd, limit, pct), false) {
+			notice = fmt.Sprintf("📊 Spend %s of %s used %d%% of the %s per-transaction limit: %s",
+				tx.ID, usd, pct, limit, tx.Description)
+		}
+	}
+	if notice != "" {
+		notices = append(notices, notice)
+	}
+	return notices
+}
+
+// crossed records that the threshold under key is over in the window that
+// started at start and reports whether that is new. Callers must hold mu.
+func (b *BudgetAlerts) crossed(key string, start time.Time, over, rearm bool) bool {
+	seen, ok := b.notified[key]
+	if !over {
+		if rearm {
+			delete(b.notified, key)
+		}
+		return false
+	}
+	if ok && seen.Equal(start) {
+		return false
+	}
+	b.notified[key] = start
+	return true
+}
+
+// reaches reports whether spent is at least pct percent of limit
+func reaches(spent, limit money.Money, pct int) bool {
+	if limit.Sign() <= 0 {
+		return false
+	}
+	return spent.Amount.Mul(money.NewFromInt(100)).Cmp(limit.Amount.Mul(money.NewFromInt(int64(pct)))) >= 0
+}
+
+func windowNotice(w agent.Window, pct int) string {
+	name := w.Period.Label() + " budget"
+	if w.Category != "" {
+		name = fmt.Sprintf("%s %s budget", w.Period.Label(), w.Category)
+	}
+
+	icon := "📊"
+	if pct >= 100 {
+		icon = "🛑"
+	}
+	return fmt.Sprintf("%s %s %d%% used: %s of %s spent, %s left (%s)",
+		icon, name, pct, w.Spent, w.Limit, w.Remaining(), windowReset(w))
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+)
+
+const (
+	defaultApprovalTimeout = time.Hour
+	reactionApprove        = "✅"
+	reactionReject         = "❌"
+)
+
+// Approvals asks admins to approve spends over the agent's limits.
+// Requests are posted to the admin room with ✅ and ❌ reactions;
+// the first admin reaction decides, and unanswered requests expire.
+type Approvals struct {
+	Client *mautrix.Client
+	Agent  *agent.Agent
+
+	// AdminRoom receives approval requests
+	AdminRoom id.RoomID
+	// IsAdmin reports whether a reacting user may decide
+	IsAdmin func(userID id.UserID) bool
+	// Timeout before an unanswered request expires (defaults to 1h)
+	Timeout time.Duration
+	// Execute pays a reserved spend, see Spends.Execute; nil only records it
+	Execute func(tx *agent.Transaction) error
+	// Alerts is told about every spend that takes budget; nil disables it
+	Alerts *BudgetAlerts
+
+	mu       sync.Mutex
+	requests map[id.EventID]string // approval message -> transaction ID
+}
+
+// Spend pays payee right away if the spend is within the limits and asks
+// the admins otherwise. The returned transaction is pending in that case.
+func (a *Approvals) Spend(category string, amount money.Money, payee, description string) (*agent.Transaction, error) {
+	tx, err := a.Agent.Reserve(category, amount, payee, description)
+	if err == nil {
+		a.Alerts.Check(tx)
+		return a.execute(tx)
+	}
+	if !errors.Is(err, agent.ErrOverLimit) {
+		return nil, err
+	}
+
+	if a.AdminRoom == "" {
+		return nil, fmt.Errorf("%w, and no admin room is configured for approval", err)
+	}
+
+	tx, err = a.Agent.RequestApproval(category, amount, payee, description, a.timeout())
+	if err != nil {
+		return nil, err
+	}
+	if err := a.post(tx); err != nil {
+		log.Error("Failed to post approval request", "transaction", tx.ID, "error", err)
+	}
+	a.schedule(tx)
+	return tx, nil
+}
+
+// Resume re-posts requests that were still pending before a restart,
+// since reactions to the old messages can no longer be matched. Spends caught
+// mid-payout are left for an admin, since SHKeeper may already have sent them.
+func (a *Approvals) Resume() error {
+	for _, tx := range a.Agent.Reservations() {
+		log.Warn("Spend was interrupted mid-payout, check SHKeeper before settling it", "transaction", tx.ID, "amount", tx.Amount)
+		a.notify(fmt.Sprintf("⚠️ Spend %s of %s to %s was interrupted during payout. Its budget stays reserved.\n\n%s",
+			tx.ID, tx.Amount, tx.Payee, settleHint(tx.ID)))
+	}
+
+	for _, pending := range a.Agent.PendingApprovals() {
+		tx := pending
+		if !time.Now().Before(tx.ExpiresAt) {
+			a.expire(tx.ID)
+			continue
+		}
+		if err := a.post(&tx); err != nil {
+			log.Error("Failed to re-post approval request", "transaction", tx.ID, "error", err)
+		}
+		a.schedule(&tx)
+	}
+	return nil
+}
+
+// HandleReaction decides a request when an admin reacts to its message
+func (a *Approvals) HandleReaction(roomID id.RoomID, sender id.UserID, eventID id.EventID, key string) {
+	if roomID != a.AdminRoom || sender == a.Client.UserID {
+		return
+	}
+
+	a.mu.Lock()
+	txID, ok := a.requests[eventID]
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+	if a.IsAdmin == nil || !a.IsAdmin(sender) {
+		log.Warn("Ignoring approval reaction from non-admin", "transaction", txID, "sender", sender)
+		return
+	}
+
+	switch key {
+	case reactionApprove:
+		a.approve(txID, sender.String())
+	case reactionReject:
+		a.reject(txID, sender.String())
+	}
+}
+
+func (a *Approvals) approve(txID, approvedBy string) {
+	tx, err := a.Agent.Approve(txID, approvedBy)
+	if errors.Is(err, agent.ErrNotPending) {
+		return
+	}
+	if errors.Is(err, agent.ErrUnknownPayee) {
+		a.notify(fmt.Sprintf("❌ Cannot approve spend %s: %v. Add the payee with !payee add, then approve again.", txID, err))
+		return
+	}
+	if err != nil {
+		log.Error("Failed to approve spend", "transaction", txID, "error", err)
+		return
+	}
+	a.forget(txID)
+
+	a.notify(fmt.Sprintf("✅ Spend %s of %s approved by %s: %s", tx.ID, tx.Amount, approvedBy, tx.Description))
+	a.Alerts.Check(tx)
+	if paid, err := a.execute(tx); err == nil {
+		a.notify(fmt.Sprintf("💸 Spend %s paid: %s to %s", paid.ID, paid.Amount, paid.Payee))
+	}
+}
+
+func (a *Approvals) reject(txID, rejectedBy string) {
+	tx, err := a.Agent.Reject(txID, rejectedBy)
+	if errors.Is(err, agent.ErrNotPending) {
+		return
+	}
+	if err != nil {
+		log.Error("Failed to reject spend", "transaction", txID, "error", err)
+		return
+	}
+	a.forget(txID)
+	a.notify(fmt.Sprintf("❌ Spend %s of %s rejected by %s", tx.ID, tx.Amount, rejectedBy))
+}
+
+func (a *Approvals) expire(txID string) {
+	tx, err := a.Agent.Expire(txID)
+	if errors.Is(err, agent.ErrNotPending) {
+		return
+	}
+	if err != nil {
+		log.Error("Failed to expire spend request", "transaction", txID, "error", err)
+		return
+	}
+	a.forget(txID)
+	a.notify(fmt.Sprintf("⏰ Spend request %s of %s expired without a decision", tx.ID, tx.Amount))
+}
+
+// execute pays a reserved spend and commits its reservation. A payment
+// that was not sent gives the budget back; one that may have been sent
+// keeps it reserved until an admin settles it.
+func (a *Approvals) execute(tx *agent.Transaction) (*agent.Transaction, error) {
+	var err error
+	if a.Execute != nil {
+		err = a.Execute(tx)
+	}
+
+	switch {
+	case err == nil:
+		committed, cerr := a.Agent.Commit(tx.ID)
+		if cerr != nil {
+			// The reservation keeps holding the budget, and retrying under the
+			// same idempotency key commits it without paying again
+			log.Error("Failed to commit spend", "transaction", tx.ID, "error", cerr)
+			return tx, nil
+		}
+		return committed, nil
+
+	case errors.Is(err, errPaymentInFlight):
+		return tx, err
+
+	case errors.Is(err, errPaymentUnknown):
+		log.Error("Spend payment outcome unknown", "transaction", tx.ID, "amount", tx.Amount, "error", err)
+		a.notify(fmt.Sprintf("⚠️ Spend %s of %s to %s may not have been paid: %v\nIts budget stays reserved.\n\n%s",
+			tx.ID, tx.Amount, tx.Payee, err, settleHint(tx.ID)))
+		return tx, err
+
+	default:
+		log.Error("Spend failed", "transaction", tx.ID, "amount", tx.Amount, "error", err)
+		a.notify(fmt.Sprintf("⚠️ Spend %s of %s failed: %v", tx.ID, tx.Amount, err))
+		failed, rerr := a.Agent.Rollback(tx.ID, err)
+		if rerr != nil {
+			log.Error("Failed to release spend budget", "transaction", tx.ID, "error", rerr)
+			return tx, err
+		}
+		return failed, err
+	}
+}
+
+// settleHint tells admins how to settle a spend whose payout outcome is unknown
+func settleHint(txID string) string {
+	return fmt.Sprintf("Check SHKeeper, then settle it:\n• It was paid: !spend sent %s\n• It was not paid: !spend retry %s to send it again, or !spend release %s to drop it",
+		txID, txID, txID)
+}
+
+// post sends the approval request to the admin room and adds the reaction buttons
+func (a *Approvals) post(tx *agent.Transaction) error {
+	limits := fmt.Sprintf("%s per transaction", a.Agent.GetSpendingLimit())
+	for _, w := range a.Agent.Windows() {
+		limits += fmt.Sprintf(", %s %s (%s spent)", strings.ToLower(w.Period.Label()), w.Limit, w.Spent)
+	}
+
+	msg := fmt.Sprintf("🛂 Spend needs approval\n\nTransaction: %s\nAmount: %s\nCategory: %s\nPayee: %s\nFor: %s\nLimits: %s\n\nReact %s to approve or %s to reject before %s.",
+		tx.ID, tx.Amount, tx.Category, tx.Payee, tx.Description, limits,
+		reactionApprove, reactionReject, tx.ExpiresAt.UTC().Format("Jan 02 15:04 MST"))
+
+	resp, err := a.Client.SendText(context.Background(), a.AdminRoom, msg)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	if a.requests == nil {
+		a.requests = make(map[id.EventID]string)
+	}
+	a.requests[resp.EventID] = tx.ID
+	a.mu.Unlock()
+
+	for _, key := range []string{reactionApprove, reactionReject} {
+		if _, err := a.Client.SendReaction(context.Background(), a.AdminRoom, resp.EventID, key); err != nil {
+			log.Warn("Failed to add approval reaction", "transaction", tx.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// schedule expires the request once its timeout passes
+func (a *Approvals) schedule(tx *agent.Transaction) {
+	txID := tx.ID
+	time.AfterFunc(time.Until(tx.ExpiresAt), func() {
+		a.expire(txID)
+	})
+}
+
+func (a *Approvals) forget(txID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for eventID, pending := range a.requests {
+		if pending == txID {
+			delete(a.requests, eventID)
+		}
+	}
+}
+
+func (a *Approvals) notify(msg string) {
+	if a.AdminRoom == "" {
+		log.Warn("No admin room configured", "message", msg)
+		return
+	}
+	Reply(&Context{Client: a.Client, RoomID: a.AdminRoom}, msg)
+}
+
+func (a *Approvals) timeout() time.Duration {
+	if a.Timeout <= 0 {
+		return defaultApprovalTimeout
+	}
+	return a.Timeout
+}
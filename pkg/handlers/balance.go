@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
 )
 
 // BalanceHandler shows agent's treasury
@@ -33,10 +36,24 @@ func (h *BalanceHandler) Handle(ctx *Context) error {
 	}
 
 	msg += fmt.Sprintf("\n📊 **Spending Limits**\n")
-	msg += fmt.Sprintf("• Per transaction: $%.2f\n", ctx.Agent.GetSpendingLimit())
-	msg += fmt.Sprintf("• Daily budget: $%.2f\n", ctx.Agent.GetDailyBudget())
-	msg += fmt.Sprintf("• Spent today: $%.2f\n", stats.SpentToday)
-	msg += fmt.Sprintf("• Remaining today: $%.2f\n", ctx.Agent.GetDailyBudget()-stats.SpentToday)
+	msg += fmt.Sprintf("• Per transaction: %s\n", ctx.Agent.GetSpendingLimit())
+	if stats.Budget.Dynamic {
+		msg += fmt.Sprintf("• Daily budget today: %s (%s)\n", stats.Budget.Limit, stats.Budget.Explain())
+	}
+	for _, w := range stats.Windows {
+		msg += fmt.Sprintf("• %s: %s of %s spent, %s left (%s)\n",
+			w.Period.Label(), w.Spent, w.Limit, w.Remaining(), windowReset(w))
+	}
+
+	if len(stats.Categories) > 0 {
+		msg += "\n🗂️ **By Category**\n"
+		for _, c := range stats.Categories {
+			msg += fmt.Sprintf("• %s: %s today, %s total\n", c.Name, c.SpentToday, c.SpentTotal)
+			for _, w := range c.Windows {
+				msg += fmt.Sprintf("  ◦ %s: %s of %s (%s)\n", w.Period.Label(), w.Spent, w.Limit, windowReset(w))
+			}
+		}
+	}
 
 	if stats.LastSpendTime.IsZero() {
 		msg += "\n✅ No spending yet today"
@@ -48,10 +65,18 @@ func (h *BalanceHandler) Handle(ctx *Context) error {
 	return nil
 }
 
+// windowReset describes when a budget window frees up again
+func windowReset(w agent.Window) string {
+	if w.ResetsAt.IsZero() {
+		return "nothing spent in the last 24h"
+	}
+	return "resets " + w.ResetsAt.Format("Mon Jan 02 15:04 MST")
+}
+
 func (h *BalanceHandler) Description() string {
 	return "Check agent treasury"
 }
 
-func (h *BalanceHandler) Price() float64 {
-	return 0
+func (h *BalanceHandler) Price() money.Money {
+	return money.Money{}
 }
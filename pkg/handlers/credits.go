@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+
+	"clawclack/pkg/credits"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+)
+
+// topupService is the command credits are bought with. Top-ups are
+// prepayments, not revenue: what they buy is booked as an earning of the
+// service the credits are spent on, once it was delivered.
+const topupService = "!topup"
+
+// PayWithCredits pays for a service from the sender's prepaid balance and
+// runs it right away. It returns credits.ErrInsufficientFunds if the balance
+// does not cover the price, in which case the caller should invoice instead.
+func (p *Payments) PayWithCredits(ctx *Context, service string, args []string, price money.Money) (*orders.Order, *credits.Entry, error) {
+	if p.Credits == nil {
+		return nil, nil, credits.ErrInsufficientFunds
+	}
+
+	orderID := uuid.New().String()
+	entry, err := p.Credits.Debit(ctx.Sender.String(), price, service, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Credit-paid orders skip the invoice; revenue is booked once it is fulfilled
+	order := &orders.Order{
+		ID:          orderID,
+		Sender:      ctx.Sender.String(),
+		RoomID:      ctx.RoomID.String(),
+		Service:     service,
+		Args:        args,
+		Price:       price.Amount,
+		Currency:    price.Currency,
+		Received:    price.Amount,
+		PaidCredits: true,
+		State:       orders.StatePaid,
+	}
+	if err := p.Orders.Create(order); err != nil {
+		if _, cerr := p.Credits.Credit(ctx.Sender.String(), credits.KindCredit, price, "Failed order, debit reversed", orderID); cerr != nil {
+			log.Error("Failed to reverse credit debit", "order", orderID, "error", cerr)
+		}
+		return nil, nil, fmt.Errorf("failed to store order: %w", err)
+	}
+
+	go p.fulfill(order)
+	return order, entry, nil
+}
+
+// credit puts money owed to a customer on their credit balance.
+// It reports false if credits are disabled or the amount has no USD value
+// at a stablecoin peg or the order's locked rate.
+func (p *Payments) credit(order *orders.Order, amount money.Money, reason string) bool {
+	if p.Credits == nil {
+		return false
+	}
+	usd, ok := order.USDValue(amount)
+	if !ok || usd.Sign() <= 0 {
+		return false
+	}
+
+	entry, err := p.Credits.Credit(order.Sender, credits.KindCredit, usd, reason, order.ID)
+	if err != nil {
+		log.Error("Failed to credit customer", "order", order.ID, "amount", usd, "error", err)
+		return false
+	}
+
+	// Money from a paid invoice was booked as an earning; once it is credited
+	// it is owed back, and it is earned again when the credits are spent
+	if !order.PaidCredits && order.Service != topupService {
+		p.Agent.RecordRefund(amount, usd, fmt.Sprintf("Credited to %s for order %s: %s", order.Sender, order.ID, reason))
+	}
+
+	Reply(p.contextFor(order), fmt.Sprintf("💳 %s from order %s was added to your credits (%s). Balance: %s",
+		usd, order.ID, reason, money.New(entry.Balance, money.USD)))
+	return true
+}
+
+// earnCredits books a credit-paid order as an earning of its own service
+func (p *Payments) earnCredits(order *orders.Order) {
+	price := order.Amount()
+	p.Agent.RecordEarn(order.Service, price, price, fmt.Sprintf("Payment for %s from credits (order %s)", order.Service, order.ID))
+}
+
+// TopUpHandler buys prepaid credits through a regular invoice
+type TopUpHandler struct{}
+
+func (h *TopUpHandler) Handle(ctx *Context) error {
+	// Parse: !topup <amount> <currency>
+	parts := strings.Fields(ctx.Message)
+	if len(parts) < 3 {
+		Reply(ctx, "Usage: !topup <amount> <currency>\nExample: !topup 5 USDT")
+		return nil
+	}
+
+	if !money.IsStablecoin(parts[2]) {
+		Reply(ctx, "❌ Credits can only be topped up with USD stablecoins (USDT, USDC).")
+		return nil
+	}
+	currency, ok := ctx.Payments.resolveCurrency(ctx, parts[2])
+	if !ok {
+		return nil
+	}
+
+	amount, err := money.Parse(parts[1], currency)
+	if err != nil || amount.Sign() <= 0 {
+		Reply(ctx, fmt.Sprintf("❌ Invalid amount %q. Example: !topup 5 USDT", parts[1]))
+		return nil
+	}
+	if _, ok := amount.USDValue(); !ok {
+		Reply(ctx, "❌ Credits can only be topped up with USD stablecoins (USDT, USDC).")
+		return nil
+	}
+
+	order, err := ctx.Payments.Invoice(ctx, topupService, nil, amount)
+	if err != nil {
+		log.Error("Failed to create top-up invoice", "error", err)
+		Reply(ctx, "⚠️ Failed to create payment invoice. Please try again.")
+		return err
+	}
+
+	Reply(ctx, fmt.Sprintf("💳 Credit Top-up\n\nAmount: %s\nOrder ID: %s\n\nPay here: %s\n%s\n\nCredits are added as soon as the payment is confirmed.",
+		amount, order.ID, order.PaymentURL, ctx.Payments.expiresIn(order)))
+	return nil
+}
+
+// Fulfill credits everything that arrived for the top-up
+func (h *TopUpHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	received := money.New(order.Received.Sub(order.Excess), order.Currency)
+	usd, ok := received.USDValue()
+	if !ok {
+		return fmt.Errorf("cannot credit %s", received)
+	}
+
+	entry, err := ctx.Payments.Credits.Credit(order.Sender, credits.KindTopUp, usd, "Top-up", order.ID)
+	if err != nil {
+		return err
+	}
+
+	Reply(ctx, fmt.Sprintf("💳 %s added to your credits. Balance: %s", usd, money.New(entry.Balance, money.USD)))
+	return nil
+}
+
+func (h *TopUpHandler) Description() string {
+	return "Buy prepaid credits"
+}
+
+func (h *TopUpHandler) Price() money.Money {
+	return money.Money{}
+}
+
+// CreditsHandler shows the sender's credit balance and recent activity
+type CreditsHandler struct{}
+
+func (h *CreditsHandler) Handle(ctx *Context) error {
+	balance, err := ctx.Payments.Credits.Balance(ctx.Sender.String())
+	if err != nil {
+		log.Error("Failed to load credits", "error", err)
+		Reply(ctx, "⚠️ Unable to load your credits right now. Try again later.")
+		return err
+	}
+
+	history, err := ctx.Payments.Credits.History(ctx.Sender.String(), 5)
+	if err != nil {
+		log.Error("Failed to load credit history", "error", err)
+	}
+
+	msg := fmt.Sprintf("💳 Your Credits\n\nBalance: %s\n", balance)
+	if len(history) > 0 {
+		msg += "\nRecent activity:\n"
+		for _, entry := range history {
+			sign := "+"
+			if entry.Kind == credits.KindDebit {
+				sign = "-"
+			}
+			msg += fmt.Sprintf("• %s %s%s %s\n", entry.Timestamp.Format("Jan 02 15:04"), sign, money.New(entry.Amount, money.USD), entry.Description)
+		}
+	}
+	msg += "\nTop up with: !topup <amount> <currency>"
+
+	Reply(ctx, msg)
+	return nil
+}
+
+func (h *CreditsHandler) Description() string {
+	return "Show your prepaid credits"
+}
+
+func (h *CreditsHandler) Price() money.Money {
+	return money.Money{}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/shkeeper"
+)
+
+// resolveCurrency maps a user-supplied currency to the SHKeeper code to
+// invoice in. It replies with the accepted options and reports false if
+// the code is unknown or exists on more than one network.
+func (p *Payments) resolveCurrency(ctx *Context, code string) (string, bool) {
+	if p.Currencies == nil {
+		return strings.ToUpper(code), true
+	}
+
+	matches, err := p.Currencies.Match(context.Background(), code)
+	switch {
+	case errors.Is(err, shkeeper.ErrUnsupportedCrypto):
+		Reply(ctx, fmt.Sprintf("❌ Currency %s not supported. Use: %s", strings.ToUpper(code), p.currencyNames()))
+		return "", false
+	case err != nil:
+		log.Error("Failed to load supported currencies", "error", err)
+		Reply(ctx, "⚠️ Unable to check supported currencies right now. Try again later.")
+		return "", false
+	case len(matches) > 1:
+		Reply(ctx, fmt.Sprintf("❌ %s is accepted on several networks, pick one: %s", strings.ToUpper(code), shkeeper.Names(matches)))
+		return "", false
+	}
+	return strings.ToUpper(matches[0].Name), true
+}
+
+// preferredCurrency returns the sender's chosen invoice currency if it is
+// still enabled on SHKeeper, falling back to the default stablecoin
+func (p *Payments) preferredCurrency(ctx *Context) string {
+	currency, err := p.Orders.Currency(ctx.Sender.String())
+	if err != nil {
+		log.Warn("Failed to load preferred currency", "sender", ctx.Sender, "error", err)
+	}
+	if currency == "" {
+		return p.invoiceCurrency()
+	}
+	if p.Currencies != nil {
+		if matches, err := p.Currencies.Match(context.Background(), currency); err != nil || len(matches) != 1 {
+			return p.invoiceCurrency()
+		}
+	}
+	return currency
+}
+
+// rateNote describes the locked exchange rate of a converted order
+func rateNote(order *orders.Order) string {
+	if order.Quote == nil || order.Quote.Pegged() {
+		return ""
+	}
+	symbol, _, _ := strings.Cut(order.Currency, "-")
+	return fmt.Sprintf("Rate: 1 %s = %s (%s)\n", symbol, money.New(order.Quote.Rate, money.USD), order.Quote.Source)
+}
+
+// invoiceCurrency picks the stablecoin that USD-priced services are invoiced in
+func (p *Payments) invoiceCurrency() string {
+	if p.Currencies == nil {
+		return defaultCurrency
+	}
+
+	cryptos, err := p.Currencies.List(context.Background())
+	if err != nil {
+		log.Warn("Failed to load supported currencies, invoicing in default", "currency", defaultCurrency, "error", err)
+		return defaultCurrency
+	}
+
+	// Prefer the default coin on any network, then any other stablecoin
+	for _, crypto := range cryptos {
+		if crypto.Symbol() == defaultCurrency {
+			return strings.ToUpper(crypto.Name)
+		}
+	}
+	for _, crypto := range cryptos {
+		if money.IsStablecoin(crypto.Name) {
+			return strings.ToUpper(crypto.Name)
+		}
+	}
+	return defaultCurrency
+}
+
+// currencyNames lists the accepted currency codes for replies
+func (p *Payments) currencyNames() string {
+	if p.Currencies == nil {
+		return defaultCurrency
+	}
+	cryptos, err := p.Currencies.List(context.Background())
+	if err != nil || len(cryptos) == 0 {
+		return defaultCurrency
+	}
+	return shkeeper.Names(cryptos)
+}
+
+// CurrencyHandler sets the currency paid services are invoiced in
+type CurrencyHandler struct{}
+
+func (h *CurrencyHandler) Handle(ctx *Context) error {
+	parts := strings.Fields(ctx.Message)
+	if len(parts) < 2 {
+		Reply(ctx, fmt.Sprintf("💱 Paid services are invoiced in %s.\n\nChange with: !currency <currency>\nAccepted: %s",
+			ctx.Payments.preferredCurrency(ctx), ctx.Payments.currencyNames()))
+		return nil
+	}
+
+	currency := ""
+	if !strings.EqualFold(parts[1], "default") {
+		var ok bool
+		if currency, ok = ctx.Payments.resolveCurrency(ctx, parts[1]); !ok {
+			return nil
+		}
+	}
+
+	if err := ctx.Payments.Orders.SetCurrency(ctx.Sender.String(), currency); err != nil {
+		log.Error("Failed to save preferred currency", "error", err)
+		Reply(ctx, "⚠️ Failed to save your currency. Please try again.")
+		return err
+	}
+
+	Reply(ctx, fmt.Sprintf("💱 Paid services will be invoiced in %s, converted from their USD price.", ctx.Payments.preferredCurrency(ctx)))
+	return nil
+}
+
+func (h *CurrencyHandler) Description() string {
+	return "Choose the currency you pay in"
+}
+
+func (h *CurrencyHandler) Price() money.Money {
+	return money.Money{}
+}
+
+// currencyList describes each accepted currency on its own line for !help and !services
+func (p *Payments) currencyList() string {
+	if p.Currencies == nil {
+		return "• " + defaultCurrency + "\n"
+	}
+	cryptos, err := p.Currencies.List(context.Background())
+	if err != nil {
+		log.Warn("Failed to load supported currencies", "error", err)
+		return "• Currently unavailable, try again later\n"
+	}
+
+	var b strings.Builder
+	for _, crypto := range cryptos {
+		b.WriteString("• " + crypto.Name)
+		if crypto.DisplayName != "" && crypto.DisplayName != crypto.Name {
+			b.WriteString(" (" + crypto.DisplayName + ")")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"maunium.net/go/mautrix/event"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+)
+
+// ExportHandler uploads the agent's transaction history for admins
+type ExportHandler struct{}
+
+func (h *ExportHandler) Handle(ctx *Context) error {
+	if !ctx.IsAdmin {
+		Reply(ctx, "❌ Only admins can export the ledger.")
+		return nil
+	}
+
+	// Parse: !export [csv|jsonl|journal] [from] [to]
+	parts := strings.Fields(ctx.Message)
+	format := agent.FormatCSV
+	if len(parts) > 1 {
+		f, err := agent.ParseExportFormat(parts[1])
+		if err != nil {
+			Reply(ctx, "Usage: !export [csv|jsonl|journal] [from YYYY-MM-DD] [to YYYY-MM-DD]")
+			return nil
+		}
+		format = f
+	}
+	var fromDay, toDay string
+	if len(parts) > 2 {
+		fromDay = parts[2]
+	}
+	if len(parts) > 3 {
+		toDay = parts[3]
+	}
+	from, to, err := agent.DayRange(fromDay, toDay, ctx.Agent.Location())
+	if err != nil {
+		Reply(ctx, fmt.Sprintf("❌ %v", err))
+		return nil
+	}
+
+	txs := ctx.Agent.Transactions(from, to)
+	var buf bytes.Buffer
+	if err := agent.Export(&buf, txs, format, ctx.Agent.Location()); err != nil {
+		log.Error("Failed to export ledger", "format", format, "error", err)
+		Reply(ctx, "⚠️ Failed to export the ledger.")
+		return err
+	}
+
+	name := exportFileName(from, to, format)
+	upload, err := ctx.Client.UploadBytesWithName(context.Background(), buf.Bytes(), format.ContentType(), name)
+	if err != nil {
+		log.Error("Failed to upload ledger export", "error", err)
+		Reply(ctx, "⚠️ Failed to upload the export. Try again later.")
+		return err
+	}
+
+	content := &event.MessageEventContent{
+		MsgType:  event.MsgFile,
+		Body:     name,
+		FileName: name,
+		URL:      upload.ContentURI.CUString(),
+		Info: &event.FileInfo{
+			MimeType: format.ContentType(),
+			Size:     buf.Len(),
+		},
+	}
+	if _, err := ctx.Client.SendMessageEvent(context.Background(), ctx.RoomID, event.EventMessage, content); err != nil {
+		log.Error("Failed to send ledger export", "error", err)
+		return err
+	}
+
+	log.Info("📤 Ledger exported", "format", format, "transactions", len(txs), "user", ctx.Sender)
+	return nil
+}
+
+func (h *ExportHandler) Description() string {
+	return "Export the transaction ledger (admins only)"
+}
+
+func (h *ExportHandler) Price() money.Money {
+	return money.Money{}
+}
+
+// exportFileName names an export after its date range
+func exportFileName(from, to time.Time, format agent.ExportFormat) string {
+	name := "clawclack-ledger"
+	if !from.IsZero() {
+		name += "-from-" + from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		name += "-to-" + to.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return name + format.Extension()
+}
@@ -1,5 +1,9 @@
 package handlers
 
+import (
+ "clawclack/pkg/money"
+)
+
 // HelpHandler shows available commands
 type HelpHandler struct{}
 
@@ -13,16 +17,21 @@ Free Commands:
 • !price <crypto> - Get current crypto price
 
 Paid Services:
-• !alert <crypto> <price> - Set price alert ($0.10)
 • !summarize <url> - Summarize any article ($0.50)
-• !image <prompt> - Generate AI image ($0.75)
 • !code <description> - Generate code snippet ($0.50)
 • !propose <idea> - I propose a custom service ($0.50-$1.00)
 
 Payment:
-• !pay <amount> <currency> - Send me money
+• !pay <usd amount> <currency> - Send me money, converted at the current rate
+• !currency <currency> - Choose the currency you pay for services in
 • !status <invoice_id> - Check payment status
+• !topup <amount> <currency> - Buy prepaid credits for instant service
+• !credits - Show your credit balance
+• !cancel <order_id> - Cancel an unpaid order
+• !refundaddress <network> <address> - Where to send your refunds
 
+Accepted currencies:
+` + ctx.Payments.currencyList() + `
 My limits: $1/transaction, $5/day
 
 Need something else? Just ask!`
@@ -35,6 +44,6 @@ func (h *HelpHandler) Description() string {
  return "Show help message"
 }
 
-func (h *HelpHandler) Price() float64 {
- return 0
+func (h *HelpHandler) Price() money.Money {
+ return money.Money{}
 }
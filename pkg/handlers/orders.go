@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/credits"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/rates"
+	"clawclack/pkg/shkeeper"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	latePollInterval    = 5 * time.Minute
+	defaultExpiry       = 30 * time.Minute
+	defaultLateWindow   = 24 * time.Hour
+	defaultCurrency     = "USDT"
+)
+
+// Fulfiller is implemented by paid handlers that deliver their service
+// once the order's invoice is confirmed
+type Fulfiller interface {
+	Fulfill(ctx *Context, order *orders.Order) error
+}
+
+// Payments watches open orders until SHKeeper settles them.
+// Orders live in the persistent store, so watching survives restarts.
+// When SHKeeper callbacks are enabled, polling is only a fallback.
+type Payments struct {
+	Client   *mautrix.Client
+	SHKeeper *shkeeper.Client
+	Agent    *agent.Agent
+	Orders   *orders.Store
+	Handlers *Registry
+	Refunds  *Refunds
+	Credits  *credits.Store
+	// Currencies lists what SHKeeper accepts (nil trusts the requested code)
+	Currencies *shkeeper.Catalog
+	// Rates converts USD prices into crypto (nil only allows stablecoins)
+	Rates *rates.Converter
+
+	// CallbackURL is passed to SHKeeper on every invoice (empty disables callbacks)
+	CallbackURL string
+	// PollInterval between status checks (defaults to 10s)
+	PollInterval time.Duration
+	// Expiry per currency for invoices SHKeeper returns without an expiry.
+	// The "DEFAULT" entry applies to currencies not listed (defaults to 30m).
+	Expiry map[string]time.Duration
+	// LateWindow is how long expired orders are still checked for late payments
+	LateWindow time.Duration
+
+	mu       sync.Mutex
+	watching map[string]bool
+}
+
+// Invoice records a new order for the sender and creates its SHKeeper invoice.
+// The order is persisted before SHKeeper is called, so a crash can never
+// leave an invoice we don't know about.
+func (p *Payments) Invoice(ctx *Context, service string, args []string, amount money.Money) (*orders.Order, error) {
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invoice amount must be positive, got %s", amount)
+	}
+
+	return p.open(&orders.Order{
+		ID:       uuid.New().String(),
+		Sender:   ctx.Sender.String(),
+		RoomID:   ctx.RoomID.String(),
+		Service:  service,
+		Args:     args,
+		Price:    amount.Amount,
+		Currency: amount.Currency,
+	})
+}
+
+// InvoiceUSD converts a USD price into currency at the current rate and
+// invoices it. The quote is locked on the order, so settlement, credits and
+// refunds all use the rate the customer was shown.
+func (p *Payments) InvoiceUSD(ctx *Context, service string, args []string, usd money.Money, currency string) (*orders.Order, error) {
+	if usd.Currency != money.USD || usd.Sign() <= 0 {
+		return nil, fmt.Errorf("invoice price must be a positive USD amount, got %s", usd)
+	}
+
+	var amount money.Money
+	var quote *rates.Quote
+	switch {
+	case p.Rates != nil:
+		var err error
+		amount, quote, err = p.Rates.Convert(context.Background(), usd, currency)
+		if err != nil {
+			return nil, err
+		}
+	case money.IsStablecoin(currency):
+		amount = money.New(usd.Amount, currency)
+	default:
+		return nil, fmt.Errorf("no exchange rates configured to price %s", currency)
+	}
+
+	return p.open(&orders.Order{
+		ID:       uuid.New().String(),
+		Sender:   ctx.Sender.String(),
+		RoomID:   ctx.RoomID.String(),
+		Service:  service,
+		Args:     args,
+		Price:    amount.Amount,
+		Currency: amount.Currency,
+		PriceUSD: usd.Amount,
+		Quote:    quote,
+	})
+}
+
+// open stores a new order, creates its invoice and starts watching it
+func (p *Payments) open(order *orders.Order) (*orders.Order, error) {
+	if err := p.Orders.Create(order); err != nil {
+		return nil, fmt.Errorf("failed to store order: %w", err)
+	}
+
+	invoice, err := p.SHKeeper.CreateInvoice(context.Background(), shkeeper.InvoiceRequest{
+		OrderID:  order.ID,
+		Amount:   order.Price,
+		Currency: order.Currency,
+		Callback: p.CallbackURL,
+	})
+	if err != nil {
+		p.fail(order, err)
+		return nil, err
+	}
+
+	order, err = p.Orders.Update(order.ID, func(o *orders.Order) error {
+		o.PaymentURL = invoice.PaymentURL
+		o.ExpiresAt = invoice.ExpiresAt
+		if o.ExpiresAt.IsZero() {
+			o.ExpiresAt = o.CreatedAt.Add(p.expiryFor(o.Currency))
+		}
+		// A market rate is only honoured while its quote is valid.
+		// Top-ups settle the rest of an order at the rate already locked.
+		if o.Quote != nil && !o.Quote.Pegged() && o.ParentID == "" && o.Quote.ValidUntil.Before(o.ExpiresAt) {
+			o.ExpiresAt = o.Quote.ValidUntil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.Watch(order.ID)
+	return order, nil
+}
+
+// expiryFor returns the configured invoice lifetime for currency
+func (p *Payments) expiryFor(currency string) time.Duration {
+	if d, ok := p.Expiry[strings.ToUpper(currency)]; ok && d > 0 {
+		return d
+	}
+	// USDT-TRC20 falls back to the USDT entry
+	symbol, _, _ := strings.Cut(strings.ToUpper(currency), "-")
+	if d, ok := p.Expiry[symbol]; ok && d > 0 {
+		return d
+	}
+	if d, ok := p.Expiry["DEFAULT"]; ok && d > 0 {
+		return d
+	}
+	return defaultExpiry
+}
+
+// expiresAt returns when the order's invoice stops accepting payment
+func (p *Payments) expiresAt(order *orders.Order) time.Time {
+	if !order.ExpiresAt.IsZero() {
+		return order.ExpiresAt
+	}
+	return order.CreatedAt.Add(p.expiryFor(order.Currency))
+}
+
+// expiresIn describes how long the order's invoice can still be paid
+func (p *Payments) expiresIn(order *orders.Order) string {
+	left := time.Until(p.expiresAt(order)).Round(time.Minute)
+	switch {
+	case left < time.Minute:
+		return "Expires in less than a minute"
+	case left < time.Hour:
+		return fmt.Sprintf("Expires in %d minutes", int(left.Minutes()))
+	default:
+		return fmt.Sprintf("Expires at %s", p.expiresAt(order).UTC().Format("Jan 02 15:04 MST"))
+	}
+}
+
+// lateDeadline returns when we stop looking for late payments on the order
+func (p *Payments) lateDeadline(order *orders.Order) time.Time {
+	window := p.LateWindow
+	if window <= 0 {
+		window = defaultLateWindow
+	}
+	return p.expiresAt(order).Add(window)
+}
+
+// Resume picks up work that was interrupted by a restart: open and recently
+// expired orders are watched again and paid orders that were never delivered
+// are fulfilled.
+func (p *Payments) Resume() error {
+	now := time.Now()
+	pending, err := p.Orders.List(func(order *orders.Order) bool {
+		if order.State == orders.StateExpired {
+			return now.Before(p.lateDeadline(order))
+		}
+		return order.State == orders.StatePending || order.State == orders.StatePaid
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list open orders: %w", err)
+	}
+
+	for _, order := range pending {
+		if order.State == orders.StatePaid {
+			log.Info("🔁 Resuming order fulfillment", "order", order.ID, "service", order.Service)
+			go p.fulfill(order)
+			continue
+		}
+		log.Info("🔁 Resuming order watch", "order", order.ID, "room", order.RoomID, "sender", order.Sender)
+		p.Watch(order.ID)
+	}
+	return nil
+}
+
+// Watch polls SHKeeper for the order in the background.
+// Calling Watch for an order that is already being watched is a no-op.
+func (p *Payments) Watch(orderID string) {
+	p.mu.Lock()
+	if p.watching == nil {
+		p.watching = make(map[string]bool)
+	}
+	if p.watching[orderID] {
+		p.mu.Unlock()
+		return
+	}
+	p.watching[orderID] = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.watching, orderID)
+			p.mu.Unlock()
+		}()
+		p.watch(orderID)
+	}()
+}
+
+func (p *Payments) watch(orderID string) {
+	order, err := p.Orders.Get(orderID)
+	if err != nil {
+		log.Error("Failed to load order", "order", orderID, "error", err)
+		return
+	}
+
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	timeout := time.NewTimer(time.Until(p.expiresAt(order)))
+	defer timeout.Stop()
+
+	lateDeadline := p.lateDeadline(order)
+
+	for {
+		select {
+		case <-ticker.C:
+			// Settled elsewhere, e.g. by a webhook callback
+			current, err := p.Orders.Get(order.ID)
+			if err == nil && current.State != orders.StatePending && current.State != orders.StateExpired {
+				return
+			}
+			if p.poll(order, false) {
+				return
+			}
+			if time.Now().After(lateDeadline) {
+				log.Info("Stopped watching expired order", "order", order.ID)
+				return
+			}
+
+		case <-timeout.C:
+			// One last check so a payment landing right at the deadline is not lost
+			if p.poll(order, true) {
+				return
+			}
+			p.expire(order)
+
+			// Keep looking for late payments, just less often
+			ticker.Reset(latePollInterval)
+		}
+	}
+}
+
+// poll checks the order once and reports whether it is settled.
+// Partial payments are only settled on the final check, since the
+// customer may still complete the original invoice before it expires.
+func (p *Payments) poll(order *orders.Order, final bool) bool {
+	status, err := p.SHKeeper.CheckPayment(context.Background(), order.ID)
+	if err != nil {
+		log.Debug("Payment check failed", "order", order.ID, "error", err)
+		return false
+	}
+
+	switch status.Status {
+	case shkeeper.StatusConfirmed:
+		p.settle(order, status)
+		return true
+	case shkeeper.StatusExpired:
+		if status.Received.Sign() > 0 {
+			p.settle(order, status)
+			return true
+		}
+		p.expire(order)
+	case shkeeper.StatusPartial:
+		if final && status.Received.Sign() > 0 {
+			p.settle(order, status)
+			return true
+		}
+	}
+	return false
+}
+
+// HandleCallback settles an order from a SHKeeper webhook callback
+func (p *Payments) HandleCallback(ctx context.Context, status *shkeeper.PaymentStatus) error {
+	order, err := p.Orders.Get(status.OrderID)
+	if errors.Is(err, orders.ErrNotFound) {
+		return shkeeper.ErrUnknownOrder
+	}
+	if err != nil {
+		return err
+	}
+
+	switch status.Status {
+	case shkeeper.StatusConfirmed:
+		p.settle(order, status)
+	case shkeeper.StatusExpired:
+		if status.Received.Sign() > 0 {
+			p.settle(order, status)
+		} else {
+			p.expire(order)
+		}
+	case shkeeper.StatusPartial:
+		// Wait for the invoice to close; the customer may still pay the rest
+		log.Info("Partial payment received", "order", order.ID, "received", status.ReceivedAmount())
+	}
+	return nil
+}
+
+// fulfill runs the paid job behind an order and posts the result to its room
+func (p *Payments) fulfill(order *orders.Order) {
+	if order.ParentID != "" {
+		// A top-up delivers by completing the order it pays for
+		p.completeParent(order)
+		return
+	}
+	var fulfiller Fulfiller
+	if p.Handlers != nil {
+		fulfiller, _ = p.Handlers.Find(order.Service).(Fulfiller)
+	}
+	if fulfiller == nil {
+		// Plain payments like !pay have nothing to deliver, so they are done
+		// once paid and Resume does not pick them up again
+		if _, _, err := p.Orders.Transition(order.ID, orders.StateFulfilled, orders.StatePaid); err != nil {
+			log.Error("Failed to mark order fulfilled", "order", order.ID, "error", err)
+		}
+		return
+	}
+
+	ctx := p.contextFor(order)
+	if err := fulfiller.Fulfill(ctx, order); err != nil {
+		log.Error("Order fulfillment failed", "order", order.ID, "service", order.Service, "error", err)
+		owed := p.failPaid(order, err)
+		Reply(ctx, fmt.Sprintf("❌ Sorry, I could not complete order %s: %v", order.ID, err))
+		if owed.Sign() > 0 {
+			p.refund(order, money.New(owed, order.Currency), fmt.Sprintf("%s failed: %v", order.Service, err))
+		}
+		return
+	}
+
+	if _, _, err := p.Orders.Transition(order.ID, orders.StateFulfilled, orders.StatePaid); err != nil {
+		log.Error("Failed to mark order fulfilled", "order", order.ID, "error", err)
+	}
+	if order.PaidCredits {
+		p.earnCredits(order)
+	}
+}
+
+// failPaid marks a paid order failed and returns what is still owed back.
+// Any overpayment was already credited or refunded when the order settled,
+// and whatever was refunded before is not refunded again.
+func (p *Payments) failPaid(order *orders.Order, cause error) money.Decimal {
+	var owed money.Decimal
+	_, err := p.Orders.Update(order.ID, func(o *orders.Order) error {
+		o.State = orders.StateFailed
+		o.Error = cause.Error()
+		owed = o.Received.Sub(o.Excess).Sub(o.Refunded)
+		o.Refunded = o.Refunded.Add(owed)
+		return nil
+	})
+	if err != nil {
+		// The customer is owed the money either way
+		log.Error("Failed to mark order failed", "order", order.ID, "error", err)
+		return order.Received.Sub(order.Excess).Sub(order.Refunded)
+	}
+	return owed
+}
+
+// fail marks an order failed and keeps the reason for whoever follows up
+func (p *Payments) fail(order *orders.Order, cause error) {
+	_, err := p.Orders.Update(order.ID, func(o *orders.Order) error {
+		o.State = orders.StateFailed
+		o.Error = cause.Error()
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to mark order failed", "order", order.ID, "error", err)
+	}
+}
+
+func (p *Payments) expire(order *orders.Order) {
+	expired, moved, err := p.Orders.Transition(order.ID, orders.StateExpired, orders.StatePending)
+	if err != nil {
+		log.Error("Failed to mark order expired", "order", order.ID, "error", err)
+		return
+	}
+	if !moved {
+		return
+	}
+	order = expired
+
+	Reply(p.contextFor(order), fmt.Sprintf("⏰ Payment expired. Order: %s", order.ID))
+
+	// An unpaid top-up leaves its parent half paid; give the customer their money back
+	if order.ParentID != "" {
+		p.cancel(order.ParentID, "top-up expired")
+	}
+}
+
+// cancel cancels an order that has not been paid in full and refunds
+// whatever already arrived for it
+func (p *Payments) cancel(orderID, reason string) (*orders.Order, error) {
+	var (
+		owed  money.Decimal
+		state orders.State
+	)
+	cancelled, err := p.Orders.Update(orderID, func(o *orders.Order) error {
+		state = o.State
+		if o.State != orders.StatePending && o.State != orders.StateUnderpaid {
+			return errNotCancellable
+		}
+		o.State = orders.StateCancelled
+		// Later payments on the same invoice only refund what comes on top
+		owed = o.Received.Sub(o.Refunded)
+		o.Refunded = o.Received
+		return nil
+	})
+	if errors.Is(err, errNotCancellable) {
+		return nil, fmt.Errorf("order is %s and can no longer be cancelled", state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Order cancelled", "order", orderID, "reason", reason)
+	if cancelled.TopUpID != "" {
+		_, _, _ = p.Orders.Transition(cancelled.TopUpID, orders.StateCancelled, orders.StatePending)
+	}
+	if owed.Sign() > 0 {
+		p.refund(cancelled, money.New(owed, cancelled.Currency), "order cancelled: "+reason)
+	}
+	return cancelled, nil
+}
+
+// refund queues money owed back to the customer and tells them about it.
+// Orders paid from credits are refunded to the credit balance.
+func (p *Payments) refund(order *orders.Order, amount money.Money, reason string) {
+	if order.PaidCredits && p.credit(order, amount, reason) {
+		return
+	}
+
+	ctx := p.contextFor(order)
+	if p.Refunds == nil {
+		Reply(ctx, fmt.Sprintf("%s for order %s will be refunded by an admin.", amount, order.ID))
+		return
+	}
+
+	refund, err := p.Refunds.Queue(order, amount, reason)
+	if err != nil {
+		log.Error("Failed to queue refund", "order", order.ID, "amount", amount, "error", err)
+		Reply(ctx, fmt.Sprintf("⚠️ I owe you %s for order %s but could not queue the refund. An admin will follow up.", amount, order.ID))
+		return
+	}
+	Reply(ctx, fmt.Sprintf("↩️ %s for order %s is queued for refund (refund %s).", amount, order.ID, refund.ID))
+}
+
+// contextFor rebuilds a handler context for the room that placed the order
+func (p *Payments) contextFor(order *orders.Order) *Context {
+	return &Context{
+		Client:   p.Client,
+		RoomID:   id.RoomID(order.RoomID),
+		Sender:   id.UserID(order.Sender),
+		Message:  strings.TrimSpace(order.Service + " " + strings.Join(order.Args, " ")),
+		SHKeeper: p.SHKeeper,
+		Agent:    p.Agent,
+		Orders:   p.Orders,
+		Payments: p,
+		Refunds:  p.Refunds,
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/money"
+	"clawclack/pkg/payees"
+)
+
+// PayeesHandler lets admins manage the address book the agent may pay to
+type PayeesHandler struct{}
+
+func (h *PayeesHandler) Handle(ctx *Context) error {
+	if !ctx.IsAdmin {
+		Reply(ctx, "❌ Only admins can manage payees.")
+		return nil
+	}
+
+	// Parse: !payee add <name> <network> <address> | !payee remove <name>
+	parts := strings.Fields(ctx.Message)
+	if len(parts) < 2 {
+		return h.list(ctx)
+	}
+
+	switch {
+	case parts[1] == "add" && len(parts) == 5:
+		payee := &payees.Payee{
+			Name:    parts[2],
+			Network: parts[3],
+			Address: parts[4],
+			AddedBy: ctx.Sender.String(),
+		}
+		if err := ctx.Payees.Add(payee); err != nil {
+			Reply(ctx, fmt.Sprintf("❌ Could not add payee: %v", err))
+			return nil
+		}
+		log.Info("📇 Payee added", "name", payee.Name, "network", payee.Network, "address", payee.Address, "by", ctx.Sender)
+		Reply(ctx, fmt.Sprintf("✅ Added payee %s\n%s: %s", payee.Name, payee.Network, payee.Address))
+
+	case parts[1] == "remove" && len(parts) == 3:
+		err := ctx.Payees.Remove(parts[2])
+		if errors.Is(err, payees.ErrNotFound) {
+			Reply(ctx, fmt.Sprintf("❌ No payee named %s", parts[2]))
+			return nil
+		}
+		if err != nil {
+			log.Error("Failed to remove payee", "name", parts[2], "error", err)
+			Reply(ctx, "⚠️ Failed to remove the payee. Please try again.")
+			return err
+		}
+		log.Info("📇 Payee removed", "name", parts[2], "by", ctx.Sender)
+		Reply(ctx, fmt.Sprintf("🗑️ Removed payee %s", parts[2]))
+
+	default:
+		Reply(ctx, fmt.Sprintf("Usage: !payees | !payee add <name> <network> <address> | !payee remove <name>\nNetworks: %s",
+			strings.Join(payees.Networks(), ", ")))
+	}
+	return nil
+}
+
+func (h *PayeesHandler) list(ctx *Context) error {
+	list, err := ctx.Payees.List()
+	if err != nil {
+		log.Error("Failed to list payees", "error", err)
+		Reply(ctx, "⚠️ Failed to load the address book.")
+		return err
+	}
+	if len(list) == 0 {
+		Reply(ctx, "📇 The address book is empty, so the agent cannot pay anyone.\nAdd a payee with: !payee add <name> <network> <address>")
+		return nil
+	}
+
+	msg := "📇 Approved payees\n"
+	for _, p := range list {
+		msg += fmt.Sprintf("\n• %s (%s): %s", p.Name, p.Network, p.Address)
+	}
+	Reply(ctx, msg)
+	return nil
+}
+
+func (h *PayeesHandler) Description() string {
+	return "Manage the addresses the agent may pay (admins only)"
+}
+
+func (h *PayeesHandler) Price() money.Money {
+	return money.Money{}
+}
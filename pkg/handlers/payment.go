@@ -3,101 +3,90 @@ package handlers
 import (
  "context"
  "fmt"
- "strconv"
  "strings"
- "time"
 
  "github.com/charmbracelet/log"
- "github.com/google/uuid"
 
- "clawclack/pkg/shkeeper"
+ "clawclack/pkg/money"
 )
 
 // PaymentHandler handles payment creation
 type PaymentHandler struct{}
 
 func (h *PaymentHandler) Handle(ctx *Context) error {
- // Parse: !pay <amount> <currency>
+ // Parse: !pay <usd amount> <currency>
  parts := strings.Fields(ctx.Message)
  if len(parts) < 3 {
-  Reply(ctx, "Usage: !pay <amount> <currency>\nExample: !pay 10 USDT")
+  Reply(ctx, "Usage: !pay <usd amount> <currency>\nExample: !pay 10 USDT or !pay $5 BTC")
   return nil
  }
 
- amount := parts[1]
- currency := strings.ToUpper(parts[2])
-
- // Validate currency
- validCurrencies := map[string]bool{
-  "USDT": true, "USDC": true, "BTC": true, "ETH": true,
- }
- if !validCurrencies[currency] {
-  Reply(ctx, fmt.Sprintf("❌ Currency %s not supported. Use: USDT, USDC, BTC, ETH", currency))
+ // Validate currency against what SHKeeper has wallets for
+ currency, ok := ctx.Payments.resolveCurrency(ctx, parts[2])
+ if !ok {
   return nil
  }
 
- // Generate unique order ID
- orderID := uuid.New().String()
+ // Amounts are in USD and converted at the current rate
+ usd, err := money.Parse(strings.TrimPrefix(parts[1], "$"), money.USD)
+ if err != nil || usd.Sign() <= 0 {
+  Reply(ctx, fmt.Sprintf("❌ Invalid amount %q. Example: !pay 10 USDT", parts[1]))
+  return nil
+ }
 
- // Create invoice via SHKeeper
- invoice, err := ctx.SHKeeper.CreateInvoice(context.Background(), shkeeper.InvoiceRequest{
-  OrderID:  orderID,
-  Amount:   amount,
-  Currency: currency,
- })
+ order, err := ctx.Payments.InvoiceUSD(ctx, "!pay", nil, usd, currency)
  if err != nil {
   log.Error("Failed to create invoice", "error", err)
   Reply(ctx, "⚠️ Failed to create payment invoice. Please try again.")
   return err
  }
 
- msg := fmt.Sprintf("💳 Payment Request\n\nAmount: %s %s\nOrder ID: %s\n\nPay here: %s\n\nExpires in 30 minutes", 
-  amount, currency, orderID, invoice.PaymentURL)
+ msg := fmt.Sprintf("💳 Payment Request\n\nAmount: %s (%s)\nOrder ID: %s\n%s\nPay here: %s\n\n%s",
+  order.Amount(), usd, order.ID, rateNote(order), order.PaymentURL, ctx.Payments.expiresIn(order))
 
  Reply(ctx, msg)
+ return nil
+}
 
- // Start monitoring payment in background
- go h.monitorPayment(ctx, orderID)
+func (h *PaymentHandler) Description() string {
+ return "Send money to agent"
+}
 
- return nil
+func (h *PaymentHandler) Price() money.Money {
+ return money.Money{}
 }
 
-func (h *PaymentHandler) monitorPayment(ctx *Context, orderID string) {
- ticker := time.NewTicker(10 * time.Second)
- defer ticker.Stop()
-
- timeout := time.After(30 * time.Minute)
-
- for {
-  select {
-  case <-ticker.C:
-   status, err := ctx.SHKeeper.CheckPayment(context.Background(), orderID)
-   if err != nil {
-    continue
-   }
-
-   if status.Status == "confirmed" {
-    Reply(ctx, fmt.Sprintf("✅ Payment confirmed!\nOrder: %s\nThank you!", orderID))
-    
-    // Convert string amount to float
-    amount, _ := strconv.ParseFloat(status.Amount, 64)
-    ctx.Agent.RecordEarn(amount, status.Currency, "Service payment")
-    return
-   }
-
-case <-timeout:
-   Reply(ctx, fmt.Sprintf("⏰ Payment expired. Order: %s", orderID))
-   return
-  }
+// CancelHandler cancels an order that has not been paid in full
+type CancelHandler struct{}
+
+func (h *CancelHandler) Handle(ctx *Context) error {
+ parts := strings.Fields(ctx.Message)
+ if len(parts) < 2 {
+  Reply(ctx, "Usage: !cancel <order_id>")
+  return nil
+ }
+
+ order, err := ctx.Orders.Get(parts[1])
+ if err != nil || order.Sender != ctx.Sender.String() && !ctx.IsAdmin {
+  Reply(ctx, "❌ Order not found.")
+  return nil
+ }
+
+ if _, err := ctx.Payments.cancel(order.ID, "cancelled by "+ctx.Sender.String()); err != nil {
+  Reply(ctx, fmt.Sprintf("❌ Could not cancel order %s: %v", order.ID, err))
+  return nil
  }
+
+ Reply(ctx, fmt.Sprintf("🛑 Order %s cancelled.", order.ID))
+ return nil
 }
 
-func (h *PaymentHandler) Description() string {
- return "Send money to agent"
+func (h *CancelHandler) Description() string {
+ return "Cancel an unpaid order"
 }
 
-func (h *PaymentHandler) Price() float64 {
- return 0
+func (h *CancelHandler) Price() money.Money {
+ return money.Money{}
 }
 
 // StatusHandler checks payment status
@@ -121,7 +110,7 @@ func (h *StatusHandler) Handle(ctx *Context) error {
  msg := fmt.Sprintf("📋 Payment Status\n\nOrder: %s\nStatus: %s", orderID, status.Status)
  
  if status.Status == "confirmed" {
-  msg += fmt.Sprintf("\nAmount: %s %s", status.Amount, status.Currency)
+  msg += fmt.Sprintf("\nAmount: %s", status.Invoiced())
  }
 
  Reply(ctx, msg)
@@ -132,6 +121,6 @@ func (h *StatusHandler) Description() string {
  return "Check payment status"
 }
 
-func (h *StatusHandler) Price() float64 {
- return 0
+func (h *StatusHandler) Price() money.Money {
+ return money.Money{}
 }
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+)
+
+const defaultProposalTTL = 30 * time.Minute
+
+var (
+	acceptReplies  = map[string]bool{"yes": true, "y": true, "ok": true, "okay": true, "deal": true, "accept": true, "sure": true}
+	declineReplies = map[string]bool{"no": true, "n": true, "cancel": true, "decline": true, "nope": true}
+
+	// counterOffer matches replies such as "$0.60", "0.6 usd" or "how about $0.60?"
+	counterOffer = regexp.MustCompile(`(?i)^(?:how about|what about|would you do|i offer|i'?ll pay|offer|counter)?\s*\$?\s*(\d+(?:\.\d{1,2})?)\s*(?:\$|usd|dollars?)?\s*[?!.]?$`)
+)
+
+// Proposal is a custom service quote waiting for the customer's answer
+type Proposal struct {
+	Idea      string
+	Price     money.Money // what the agent currently asks
+	Floor     money.Money // lowest counter-offer it accepts
+	ExpiresAt time.Time
+}
+
+type proposalKey struct {
+	room   id.RoomID
+	sender id.UserID
+}
+
+// Proposals holds the open !propose quote of each sender in each room until
+// it is answered or its TTL passes. A new !propose replaces the old quote.
+type Proposals struct {
+	// TTL of a quote (defaults to 30 minutes)
+	TTL time.Duration
+
+	mu   sync.Mutex
+	open map[proposalKey]*Proposal
+}
+
+// Put opens a quote for the sender of ctx, replacing any previous one
+func (p *Proposals) Put(ctx *Context, proposal *Proposal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.open == nil {
+		p.open = make(map[proposalKey]*Proposal)
+	}
+
+	now := time.Now()
+	for key, open := range p.open {
+		if !now.Before(open.ExpiresAt) {
+			delete(p.open, key)
+		}
+	}
+	proposal.ExpiresAt = now.Add(p.ttl())
+	p.open[proposalKey{ctx.RoomID, ctx.Sender}] = proposal
+}
+
+// take removes and returns the open quote of the sender of ctx. expired is
+// set when the quote's TTL had already passed.
+func (p *Proposals) take(ctx *Context) (proposal *Proposal, expired bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := proposalKey{ctx.RoomID, ctx.Sender}
+	proposal, ok := p.open[key]
+	if !ok {
+		return nil, false
+	}
+	delete(p.open, key)
+	if !time.Now().Before(proposal.ExpiresAt) {
+		return nil, true
+	}
+	return proposal, false
+}
+
+// restore puts back a quote that was taken but not settled
+func (p *Proposals) restore(ctx *Context, proposal *Proposal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := proposalKey{ctx.RoomID, ctx.Sender}
+	if _, replaced := p.open[key]; !replaced {
+		p.open[key] = proposal
+	}
+}
+
+// HandleReply answers a quote with a plain "yes", "no" or a counter-offer
+// such as "$0.60". It reports whether the message was such an answer.
+func (p *Proposals) HandleReply(ctx *Context) bool {
+	if p == nil {
+		return false
+	}
+	answer := strings.ToLower(strings.Trim(strings.TrimSpace(ctx.Message), "!. "))
+	match := counterOffer.FindStringSubmatch(strings.TrimSpace(ctx.Message))
+	if !acceptReplies[answer] && !declineReplies[answer] && match == nil {
+		return false
+	}
+
+	proposal, expired := p.take(ctx)
+	if expired {
+		Reply(ctx, "⏰ That proposal has expired. Send !propose again for a fresh quote.")
+		return true
+	}
+	if proposal == nil {
+		return false
+	}
+
+	switch {
+	case declineReplies[answer]:
+		Reply(ctx, "👍 No problem, the proposal is cancelled.")
+		log.Info("Custom service declined", "idea", proposal.Idea, "user", ctx.Sender)
+
+	case acceptReplies[answer]:
+		p.accept(ctx, proposal, proposal.Price)
+
+	default:
+		offer, err := money.Parse(match[1], money.USD)
+		if err != nil {
+			p.restore(ctx, proposal)
+			return false
+		}
+		if offer.Cmp(proposal.Floor) < 0 {
+			p.restore(ctx, proposal)
+			Reply(ctx, fmt.Sprintf("🤝 I can't go as low as %s for this one. Reply \"yes\" for %s, or offer at least %s.",
+				offer, proposal.Price, proposal.Floor))
+			log.Info("Counter-offer below floor", "idea", proposal.Idea, "offer", offer, "floor", proposal.Floor, "user", ctx.Sender)
+			return true
+		}
+		// An offer above the quote is accepted at the quote
+		if offer.Cmp(proposal.Price) > 0 {
+			offer = proposal.Price
+		}
+		p.accept(ctx, proposal, offer)
+	}
+	return true
+}
+
+// accept turns the quote into an invoice-backed !propose order. A quote
+// whose LLM went away since it was made is dropped rather than invoiced.
+func (p *Proposals) accept(ctx *Context, proposal *Proposal, price money.Money) {
+	if !ctx.Agent.HasLLM() {
+		Reply(ctx, fmt.Sprintf("❌ Cannot take on the custom service: %v", errServiceUnavailable))
+		log.Warn("Custom service accepted but no LLM is configured", "idea", proposal.Idea, "user", ctx.Sender)
+		return
+	}
+	log.Info("Custom service accepted", "idea", proposal.Idea, "price", price, "user", ctx.Sender)
+	err := requestPayment(ctx, "!propose", strings.Fields(proposal.Idea), price,
+		fmt.Sprintf("🤝 Deal! Custom service for %s\nRequest: %s", price, proposal.Idea))
+	if err != nil {
+		// The customer can say yes again once invoicing works
+		p.restore(ctx, proposal)
+	}
+}
+
+func (p *Proposals) ttl() time.Duration {
+	if p.TTL <= 0 {
+		return defaultProposalTTL
+	}
+	return p.TTL
+}
+
+// ProposalHistory gives the agent the prices of custom services customers
+// paid for, so new proposals are priced like similar earlier ones
+type ProposalHistory struct {
+	Orders *orders.Store
+}
+
+// AcceptedPrices returns every paid !propose order
+func (h *ProposalHistory) AcceptedPrices() ([]agent.AcceptedPrice, error) {
+	paid, err := h.Orders.List(func(o *orders.Order) bool {
+		return o.Service == "!propose" && o.ParentID == "" && !o.PriceUSD.IsZero() &&
+			(o.State == orders.StatePaid || o.State == orders.StateFulfilled)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make([]agent.AcceptedPrice, 0, len(paid))
+	for _, o := range paid {
+		prices = append(prices, agent.AcceptedPrice{
+			Description: strings.Join(o.Args, " "),
+			Price:       money.New(o.PriceUSD, money.USD),
+			AcceptedAt:  o.CreatedAt,
+		})
+	}
+	return prices, nil
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/payees"
+	"clawclack/pkg/refunds"
+	"clawclack/pkg/shkeeper"
+)
+
+// Refunds pays money back to customers through SHKeeper payouts.
+// Refunds up to AutoApprove are approved by policy; larger ones wait for an admin.
+type Refunds struct {
+	Client   *mautrix.Client
+	SHKeeper *shkeeper.Client
+	Agent    *agent.Agent
+	Store    *refunds.Store
+
+	// AdminRoom receives refunds that need approval and failed payouts
+	AdminRoom id.RoomID
+	// AutoApprove is the largest USD value refunded without an admin
+	AutoApprove money.Money
+}
+
+// Queue records a refund for an order and approves it right away if policy allows
+func (r *Refunds) Queue(order *orders.Order, amount money.Money, reason string) (*refunds.Refund, error) {
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("refund amount must be positive, got %s", amount)
+	}
+
+	refund := &refunds.Refund{
+		ID:       uuid.New().String(),
+		OrderID:  order.ID,
+		Service:  order.Service,
+		Sender:   order.Sender,
+		RoomID:   order.RoomID,
+		Amount:   amount.Amount,
+		Currency: amount.Currency,
+		Network:  money.Network(amount.Currency),
+		Reason:   reason,
+	}
+	if usd, ok := order.USDValue(amount); ok {
+		refund.ValueUSD = usd.Amount
+	}
+	if err := r.Store.Create(refund); err != nil {
+		return nil, fmt.Errorf("failed to store refund: %w", err)
+	}
+
+	log.Info("↩️ Refund queued", "refund", refund.ID, "order", order.ID, "amount", amount, "reason", reason)
+
+	if r.autoApproved(amount) && refund.Network != "" {
+		go func() {
+			if _, err := r.Approve(refund.ID, "policy"); err != nil {
+				log.Error("Failed to auto-approve refund", "refund", refund.ID, "error", err)
+			}
+		}()
+		return refund, nil
+	}
+
+	r.notifyAdmins(fmt.Sprintf("↩️ Refund needs approval\n\nRefund: %s\nOrder: %s\nUser: %s\nAmount: %s\nReason: %s\n\nApprove with: !refund approve %s\nReject with: !refund reject %s",
+		refund.ID, order.ID, order.Sender, amount, reason, refund.ID, refund.ID))
+	return refund, nil
+}
+
+func (r *Refunds) autoApproved(amount money.Money) bool {
+	usd, ok := amount.USDValue()
+	return ok && !r.AutoApprove.IsZero() && usd.Cmp(r.AutoApprove) <= 0
+}
+
+// Approve approves a queued refund and sends it if the customer has an address
+func (r *Refunds) Approve(refundID, approvedBy string) (*refunds.Refund, error) {
+	refund, moved, err := r.Store.Transition(refundID, refunds.StateApproved, func(rf *refunds.Refund) {
+		rf.ApprovedBy = approvedBy
+	}, refunds.StateQueued)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return refund, fmt.Errorf("refund is %s, not queued", refund.State)
+	}
+
+	r.send(refund)
+	return refund, nil
+}
+
+// Reject closes a queued or failed refund without paying it
+func (r *Refunds) Reject(refundID, rejectedBy, reason string) (*refunds.Refund, error) {
+	refund, moved, err := r.Store.Transition(refundID, refunds.StateRejected, func(rf *refunds.Refund) {
+		rf.ApprovedBy = rejectedBy
+		rf.Error = reason
+	}, refunds.StateQueued, refunds.StateFailed)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return refund, fmt.Errorf("refund is %s and can no longer be rejected", refund.State)
+	}
+
+	r.notifyUser(refund, fmt.Sprintf("↩️ Your refund of %s for order %s was declined: %s", refund.Money(), refund.OrderID, reason))
+	return refund, nil
+}
+
+// Retry sends a failed refund again
+func (r *Refunds) Retry(refundID string) (*refunds.Refund, error) {
+	refund, moved, err := r.Store.Transition(refundID, refunds.StateApproved, func(rf *refunds.Refund) {
+		rf.Error = ""
+	}, refunds.StateFailed)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return refund, fmt.Errorf("refund is %s, not failed", refund.State)
+	}
+
+	r.send(refund)
+	return refund, nil
+}
+
+// SendPending sends every approved refund that was waiting for the user's address on network
+func (r *Refunds) SendPending(userID id.UserID, network string) {
+	waiting, err := r.Store.List(func(rf *refunds.Refund) bool {
+		return rf.State == refunds.StateApproved && rf.Sender == userID.String() && rf.Network == network
+	})
+	if err != nil {
+		log.Error("Failed to list waiting refunds", "user", userID, "error", err)
+		return
+	}
+	for _, refund := range waiting {
+		r.send(refund)
+	}
+}
+
+// Resume sends approved refunds that were interrupted by a restart.
+// Refunds caught mid-payout are left for an admin, since SHKeeper may
+// already have sent them.
+func (r *Refunds) Resume() error {
+	open, err := r.Store.List(func(rf *refunds.Refund) bool {
+		return rf.State == refunds.StateApproved || rf.State == refunds.StateSending
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list refunds: %w", err)
+	}
+
+	for _, refund := range open {
+		if refund.State == refunds.StateSending {
+			log.Warn("Refund was interrupted mid-payout, check SHKeeper before retrying", "refund", refund.ID)
+			r.notifyAdmins(fmt.Sprintf("⚠️ Refund %s (%s) was interrupted during payout. Check SHKeeper, then settle it with: !refund sent %s\nIf it was not sent: !refund unsent %s",
+				refund.ID, refund.Money(), refund.ID, refund.ID))
+			continue
+		}
+		go r.send(refund)
+	}
+	return nil
+}
+
+// send pays out an approved refund exactly once
+func (r *Refunds) send(refund *refunds.Refund) {
+	if refund.Network == "" {
+		r.notifyAdmins(fmt.Sprintf("⚠️ Refund %s is in %s, which has no known payout network. Please refund it manually.", refund.ID, refund.Currency))
+		return
+	}
+
+	address, err := r.Store.Address(refund.Sender, refund.Network)
+	if err != nil {
+		log.Error("Failed to look up refund address", "refund", refund.ID, "error", err)
+		return
+	}
+	if address == "" {
+		r.notifyUser(refund, fmt.Sprintf("↩️ A refund of %s for order %s is ready.\nTell me where to send it with: !refundaddress %s <address>",
+			refund.Money(), refund.OrderID, refund.Network))
+		return
+	}
+	// Addresses saved before they were validated are checked here too
+	if address, err = payees.NormalizeAddress(refund.Network, address); err != nil {
+		log.Warn("Invalid refund address on file", "refund", refund.ID, "network", refund.Network, "error", err)
+		r.notifyUser(refund, fmt.Sprintf("↩️ A refund of %s for order %s is ready, but your %s refund address is invalid: %v\nSet a new one with: !refundaddress %s <address>",
+			refund.Money(), refund.OrderID, refund.Network, err, refund.Network))
+		return
+	}
+
+	// Moving to sending first guarantees only one caller ever reaches SendPayment
+	sending, moved, err := r.Store.Transition(refund.ID, refunds.StateSending, func(rf *refunds.Refund) {
+		rf.Address = address
+	}, refunds.StateApproved)
+	if err != nil {
+		log.Error("Failed to start refund payout", "refund", refund.ID, "error", err)
+		return
+	}
+	if !moved {
+		return
+	}
+
+	if err := r.SHKeeper.SendPayment(context.Background(), sending.Money(), address, "refund-"+sending.ID); err != nil {
+		// Only a refusal proves nothing left the wallet; a retry after a
+		// timeout or server error could pay the customer twice
+		next := refunds.StateUnknown
+		if notSent(err) {
+			next = refunds.StateFailed
+		}
+		log.Error("Refund payout failed", "refund", sending.ID, "state", next, "error", err)
+		if _, _, terr := r.Store.Transition(sending.ID, next, func(rf *refunds.Refund) {
+			rf.Error = err.Error()
+		}, refunds.StateSending); terr != nil {
+			log.Error("Failed to record refund payout error", "refund", sending.ID, "error", terr)
+		}
+
+		if next == refunds.StateFailed {
+			r.notifyAdmins(fmt.Sprintf("⚠️ Refund payout failed\n\nRefund: %s\nAmount: %s\nError: %v\n\nRetry with: !refund retry %s", sending.ID, sending.Money(), err, sending.ID))
+		} else {
+			r.notifyAdmins(fmt.Sprintf("⚠️ Refund %s of %s to %s may not have been paid: %v\n\nCheck SHKeeper, then settle it with: !refund sent %s\nIf it was not sent: !refund unsent %s",
+				sending.ID, sending.Money(), address, err, sending.ID, sending.ID))
+		}
+		return
+	}
+
+	if _, _, err := r.Store.Transition(sending.ID, refunds.StateSent, nil, refunds.StateSending); err != nil {
+		log.Error("Failed to mark refund sent", "refund", sending.ID, "error", err)
+	}
+	r.complete(sending)
+}
+
+// Resolve settles a refund whose payout outcome was unknown once an admin
+// checked SHKeeper: sent records it as paid, otherwise it fails and can be retried
+func (r *Refunds) Resolve(refundID string, sent bool, resolvedBy string) (*refunds.Refund, error) {
+	next := refunds.StateFailed
+	if sent {
+		next = refunds.StateSent
+	}
+	refund, moved, err := r.Store.Transition(refundID, next, func(rf *refunds.Refund) {
+		if !sent {
+			rf.Error = "not sent, checked by " + resolvedBy
+		}
+	}, refunds.StateUnknown, refunds.StateSending)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return refund, fmt.Errorf("refund is %s, its payout outcome is known", refund.State)
+	}
+
+	if sent {
+		r.complete(refund)
+	}
+	return refund, nil
+}
+
+// complete books a refund that left the wallet and tells the customer
+func (r *Refunds) complete(refund *refunds.Refund) {
+	// Top-ups were never booked as revenue, so paying one back books nothing
+	if refund.Service != topupService {
+		r.Agent.RecordRefund(refund.Money(), money.New(refund.ValueUSD, money.USD), fmt.Sprintf("Refund %s for order %s: %s", refund.ID, refund.OrderID, refund.Reason))
+	}
+	r.notifyUser(refund, fmt.Sprintf("↩️ Refunded %s for order %s to %s", refund.Money(), refund.OrderID, refund.Address))
+}
+
+func (r *Refunds) notifyUser(refund *refunds.Refund, msg string) {
+	Reply(&Context{Client: r.Client, RoomID: id.RoomID(refund.RoomID)}, msg)
+}
+
+func (r *Refunds) notifyAdmins(msg string) {
+	if r.AdminRoom == "" {
+		log.Warn("No admin room configured", "message", msg)
+		return
+	}
+	Reply(&Context{Client: r.Client, RoomID: r.AdminRoom}, msg)
+}
+
+// RefundAddressHandler registers where a user's refunds are sent
+type RefundAddressHandler struct{}
+
+func (h *RefundAddressHandler) Handle(ctx *Context) error {
+	parts := strings.Fields(ctx.Message)
+
+	if len(parts) < 3 {
+		addresses, err := ctx.Refunds.Store.Addresses(ctx.Sender.String())
+		if err != nil {
+			Reply(ctx, "⚠️ Could not load your refund addresses. Try again later.")
+			return err
+		}
+
+		msg := fmt.Sprintf("Usage: !refundaddress <network> <address>\nNetworks: %s\n", strings.Join(payees.Networks(), ", "))
+		if len(addresses) > 0 {
+			networks := make([]string, 0, len(addresses))
+			for network := range addresses {
+				networks = append(networks, network)
+			}
+			sort.Strings(networks)
+
+			msg += "\nYour refund addresses:\n"
+			for _, network := range networks {
+				msg += fmt.Sprintf("• %s: %s\n", network, addresses[network])
+			}
+		}
+		Reply(ctx, msg)
+		return nil
+	}
+
+	network := strings.ToLower(parts[1])
+	address := parts[2]
+
+	known := false
+	for _, n := range payees.Networks() {
+		known = known || n == network
+	}
+	if !known {
+		Reply(ctx, fmt.Sprintf("❌ Unknown network %s. Use: %s", network, strings.Join(payees.Networks(), ", ")))
+		return nil
+	}
+	// A malformed or wrong-chain address would send the refund nowhere
+	address, err := payees.NormalizeAddress(network, address)
+	if err != nil {
+		Reply(ctx, fmt.Sprintf("❌ That is not a valid %s address: %v", network, err))
+		return nil
+	}
+
+	if err := ctx.Refunds.Store.SetAddress(ctx.Sender.String(), network, address); err != nil {
+		log.Error("Failed to save refund address", "error", err)
+		Reply(ctx, "⚠️ Could not save your refund address. Try again later.")
+		return err
+	}
+
+	Reply(ctx, fmt.Sprintf("✅ Refunds on %s will be sent to %s", network, address))
+
+	// Anything already approved for this network can go out now
+	go ctx.Refunds.SendPending(ctx.Sender, network)
+	return nil
+}
+
+func (h *RefundAddressHandler) Description() string {
+	return "Set your refund address for a network"
+}
+
+func (h *RefundAddressHandler) Price() money.Money {
+	return money.Money{}
+}
+
+// RefundsHandler lets admins review and approve the refund queue
+type RefundsHandler struct{}
+
+func (h *RefundsHandler) Handle(ctx *Context) error {
+	if !ctx.IsAdmin {
+		Reply(ctx, "❌ Only admins can manage refunds.")
+		return nil
+	}
+
+	parts := strings.Fields(ctx.Message)
+	if len(parts) < 3 {
+		return h.list(ctx)
+	}
+
+	refundID := parts[2]
+	var (
+		refund *refunds.Refund
+		err    error
+	)
+	switch parts[1] {
+	case "approve":
+		refund, err = ctx.Refunds.Approve(refundID, ctx.Sender.String())
+	case "reject":
+		reason := "rejected by admin"
+		if len(parts) > 3 {
+			reason = strings.Join(parts[3:], " ")
+		}
+		refund, err = ctx.Refunds.Reject(refundID, ctx.Sender.String(), reason)
+	case "retry":
+		refund, err = ctx.Refunds.Retry(refundID)
+	case "sent", "unsent":
+		// Only after checking in SHKeeper whether the payout went out
+		refund, err = ctx.Refunds.Resolve(refundID, parts[1] == "sent", ctx.Sender.String())
+	default:
+		Reply(ctx, "Usage: !refunds | !refund approve|reject|retry|sent|unsent <refund_id> [reason]")
+		return nil
+	}
+	if err != nil {
+		Reply(ctx, fmt.Sprintf("❌ Could not %s refund %s: %v", parts[1], refundID, err))
+		return nil
+	}
+
+	Reply(ctx, fmt.Sprintf("✅ Refund %s is now %s", refund.ID, refund.State))
+	return nil
+}
+
+func (h *RefundsHandler) list(ctx *Context) error {
+	open, err := ctx.Refunds.Store.List(func(rf *refunds.Refund) bool {
+		return rf.State == refunds.StateQueued || rf.State == refunds.StateApproved ||
+			rf.State == refunds.StateSending || rf.State == refunds.StateFailed || rf.State == refunds.StateUnknown
+	})
+	if err != nil {
+		Reply(ctx, "⚠️ Could not load the refund queue.")
+		return err
+	}
+	if len(open) == 0 {
+		Reply(ctx, "✅ Refund queue is empty")
+		return nil
+	}
+
+	sort.Slice(open, func(i, j int) bool { return open[i].CreatedAt.Before(open[j].CreatedAt) })
+
+	msg := "↩️ Refund Queue\n\n"
+	for _, refund := range open {
+		msg += fmt.Sprintf("• %s [%s] %s to %s\n  Order %s: %s\n", refund.ID, refund.State, refund.Money(), refund.Sender, refund.OrderID, refund.Reason)
+	}
+	Reply(ctx, msg)
+	return nil
+}
+
+func (h *RefundsHandler) Description() string {
+	return "Review and approve refunds (admin)"
+}
+
+func (h *RefundsHandler) Price() money.Money {
+	return money.Money{}
+}
@@ -8,24 +8,34 @@ import (
  "maunium.net/go/mautrix/id"
 
  "clawclack/pkg/agent"
+ "clawclack/pkg/money"
+ "clawclack/pkg/orders"
+ "clawclack/pkg/payees"
  "clawclack/pkg/shkeeper"
 )
 
 // Context holds all dependencies for handlers
 type Context struct {
- Client   *mautrix.Client
- RoomID   id.RoomID
- Sender   id.UserID
- Message  string
- SHKeeper *shkeeper.Client
- Agent    *agent.Agent
+ Client    *mautrix.Client
+ RoomID    id.RoomID
+ Sender    id.UserID
+ Message   string
+ SHKeeper  *shkeeper.Client
+ Agent     *agent.Agent
+ Orders    *orders.Store
+ Payments  *Payments
+ Refunds   *Refunds
+ Approvals *Approvals
+ Proposals *Proposals
+ Payees    *payees.Store
+ IsAdmin   bool
 }
 
 // Handler interface for command handlers
 type Handler interface {
  Handle(ctx *Context) error
  Description() string
- Price() float64
+ Price() money.Money
 }
 
 // Registry holds all command handlers
@@ -43,13 +53,18 @@ func (r *Registry) Register(prefix string, handler Handler) {
  r.handlers[prefix] = handler
 }
 
+// Find returns the handler with the longest prefix matching message,
+// so "!refundaddress" is not swallowed by "!refund"
 func (r *Registry) Find(message string) Handler {
+ var found Handler
+ longest := 0
  for prefix, handler := range r.handlers {
-  if len(message) >= len(prefix) && message[:len(prefix)] == prefix {
-   return handler
+  if len(prefix) > longest && len(message) >= len(prefix) && message[:len(prefix)] == prefix {
+   found = handler
+   longest = len(prefix)
   }
  }
- return nil
+ return found
 }
 
 func (r *Registry) List() map[string]Handler {
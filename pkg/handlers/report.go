@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+)
+
+// ReportHandler shows admins profit and loss for the current period
+type ReportHandler struct{}
+
+func (h *ReportHandler) Handle(ctx *Context) error {
+	if !ctx.IsAdmin {
+		Reply(ctx, "❌ Only admins can view reports.")
+		return nil
+	}
+
+	period := agent.PeriodDay
+	if parts := strings.Fields(ctx.Message); len(parts) > 1 {
+		p, err := agent.ParseReportPeriod(strings.ToLower(parts[1]))
+		if err != nil {
+			Reply(ctx, "Usage: !report [day|week|month]")
+			return nil
+		}
+		period = p
+	}
+
+	ReplyWithHTML(ctx, formatReport(ctx.Agent.Report(period, time.Now())))
+	return nil
+}
+
+func (h *ReportHandler) Description() string {
+	return "Profit and loss for the day, week or month (admins only)"
+}
+
+func (h *ReportHandler) Price() money.Money {
+	return money.Money{}
+}
+
+// formatReport renders a report as a chat message
+func formatReport(r agent.Report) string {
+	msg := fmt.Sprintf("📈 **%s Report**\n%s – %s\n\n",
+		r.Period.Label(), r.Start.Format("Mon Jan 02 15:04"), r.End.Format("Mon Jan 02 15:04 MST"))
+
+	msg += fmt.Sprintf("💵 **Revenue:** %s from %d payments\n", r.Revenue, r.Earnings)
+	for _, l := range r.RevenueByService {
+		msg += fmt.Sprintf("• %s: %s (%d)\n", l.Name, l.Amount, l.Count)
+	}
+
+	msg += fmt.Sprintf("\n💸 **Spent:** %s in %d transactions\n", r.Spent, r.Spends)
+	for _, l := range r.SpentByCategory {
+		msg += fmt.Sprintf("• %s: %s (%d)\n", l.Name, l.Amount, l.Count)
+	}
+
+	if r.Refunds > 0 {
+		msg += fmt.Sprintf("\n↩️ **Refunded:** %s in %d refunds\n", r.Refunded, r.Refunds)
+	}
+
+	icon := "🟢"
+	if r.Net.Sign() < 0 {
+		icon = "🔴"
+	}
+	msg += fmt.Sprintf("\n%s **Net profit:** %s\n", icon, r.Net)
+	msg += fmt.Sprintf("Average sale: %s, average spend: %s\n", r.AverageSale(), r.AverageSpend())
+
+	if r.Unvalued > 0 {
+		msg += fmt.Sprintf("\n⚠️ %d transactions have no USD value and are left out", r.Unvalued)
+	}
+	return msg
+}
+
+// Reports posts the report of each period to a room as the period ends
+type Reports struct {
+	Client  *mautrix.Client
+	Agent   *agent.Agent
+	Room    id.RoomID
+	Periods []agent.Period
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start schedules the reports; it does nothing without a room or periods
+func (r *Reports) Start() {
+	if r.Room == "" || len(r.Periods) == 0 {
+		return
+	}
+
+	r.stop = make(chan struct{})
+	for _, p := range r.Periods {
+		r.wg.Add(1)
+		go r.run(p)
+	}
+	log.Info("📈 Scheduled reports", "room", r.Room, "periods", r.Periods)
+}
+
+// Stop cancels the scheduled reports
+func (r *Reports) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	r.wg.Wait()
+}
+
+func (r *Reports) run(p agent.Period) {
+	defer r.wg.Done()
+
+	_, end := r.Agent.Bounds(p, time.Now())
+	for {
+		timer := time.NewTimer(time.Until(end))
+		select {
+		case <-r.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// The last instant before end still belongs to the period that ended
+		report := r.Agent.Report(p, end.Add(-time.Nanosecond))
+		ReplyWithHTML(&Context{Client: r.Client, RoomID: r.Room}, formatReport(report))
+		log.Info("📈 Posted report", "period", p, "net", report.Net)
+
+		_, end = r.Agent.Bounds(p, end)
+	}
+}
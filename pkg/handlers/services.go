@@ -1,12 +1,50 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/article"
+	"clawclack/pkg/credits"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
 )
 
+// errServiceUnavailable is returned by fulfillers whose backend is not wired up yet
+var errServiceUnavailable = errors.New("this service is temporarily unavailable")
+
+// requestPayment invoices the sender for a paid service. The job itself runs
+// from the handler's Fulfill method once the invoice is confirmed.
+func requestPayment(ctx *Context, service string, args []string, price money.Money, summary string) error {
+	// Prepaid credits skip the invoice entirely
+	order, entry, err := ctx.Payments.PayWithCredits(ctx, service, args, price)
+	if err == nil {
+		Reply(ctx, fmt.Sprintf("%s\n\n💳 Paid %s from your credits (balance: %s).\nOrder ID: %s\n\nWorking on it...",
+			summary, price, money.New(entry.Balance, money.USD), order.ID))
+		return nil
+	}
+	if !errors.Is(err, credits.ErrInsufficientFunds) {
+		log.Error("Failed to pay with credits", "service", service, "error", err)
+	}
+
+	// The USD price is converted into the sender's preferred currency
+	order, err = ctx.Payments.InvoiceUSD(ctx, service, args, price, ctx.Payments.preferredCurrency(ctx))
+	if err != nil {
+		log.Error("Failed to create invoice", "service", service, "error", err)
+		Reply(ctx, "⚠️ Failed to create payment invoice. Please try again.")
+		return err
+	}
+
+	Reply(ctx, fmt.Sprintf("%s\n\nThis service costs %s.\nPay %s here: %s\nOrder ID: %s\n%s%s\n\nI'll start as soon as the payment is confirmed.",
+		summary, price, order.Amount(), order.PaymentURL, order.ID, rateNote(order), ctx.Payments.expiresIn(order)))
+	return nil
+}
+
 // AlertHandler - Price alerts ($0.10)
 type AlertHandler struct{}
 
@@ -18,38 +56,37 @@ func (h *AlertHandler) Handle(ctx *Context) error {
 		return nil
 	}
 
-	crypto := strings.ToUpper(parts[1])
-	targetPrice := parts[2]
-
-	price := 0.10
-
-	// Check if agent can afford this
-	canSpend, reason := ctx.Agent.CanSpend(price)
-	if !canSpend {
-		Reply(ctx, fmt.Sprintf("❌ Cannot create alert: %s", reason))
-		return nil
-	}
-
-	// Create payment invoice first
-	Reply(ctx, fmt.Sprintf("💳 Price alert for %s at $%s\n\nThis service costs $%.2f. Please pay with:\n!pay %.2f USDT", 
-		crypto, targetPrice, price, price))
-
-	// Note: In production, you'd wait for payment confirmation before setting up alert
-	log.Info("Price alert requested", "crypto", crypto, "price", targetPrice, "user", ctx.Sender)
-
+	// Nothing watches prices yet, so nobody is invoiced for an alert
+	log.Info("Price alert requested but unavailable", "crypto", strings.ToUpper(parts[1]), "price", parts[2], "user", ctx.Sender)
+	Reply(ctx, fmt.Sprintf("❌ Cannot create alert: %v", errServiceUnavailable))
 	return nil
 }
 
+// Fulfill sets up the alert after payment. Orders placed before alerts were
+// switched off are refunded through the failure path.
+func (h *AlertHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	return errServiceUnavailable
+}
+
 func (h *AlertHandler) Description() string {
 	return "Set price alert for any cryptocurrency"
 }
 
-func (h *AlertHandler) Price() float64 {
-	return 0.10
+func (h *AlertHandler) Price() money.Money {
+	return money.Dollars("0.10")
 }
 
+const (
+	// summaryChunkChars keeps each LLM request well inside small context windows
+	summaryChunkChars = 12000
+	// maxSummaryChunks bounds what one summary can cost; the rest is left out
+	maxSummaryChunks = 8
+)
+
 // SummarizeHandler - Article summarization ($0.50)
-type SummarizeHandler struct{}
+type SummarizeHandler struct {
+	Fetcher *article.Fetcher
+}
 
 func (h *SummarizeHandler) Handle(ctx *Context) error {
 	// Parse: !summarize <url>
@@ -59,35 +96,86 @@ func (h *SummarizeHandler) Handle(ctx *Context) error {
 		return nil
 	}
 
-	url := parts[1]
-	price := 0.50
+	pageURL := parts[1]
+	if !ctx.Agent.HasLLM() || h.Fetcher == nil {
+		Reply(ctx, fmt.Sprintf("❌ Cannot summarize: %v", errServiceUnavailable))
+		return nil
+	}
+	// Refuse links to internal addresses before anyone pays for them
+	if err := h.Fetcher.Check(context.Background(), pageURL); err != nil {
+		log.Warn("Refused summary URL", "url", pageURL, "user", ctx.Sender, "error", err)
+		Reply(ctx, fmt.Sprintf("❌ I can't fetch that link: %v", err))
+		return nil
+	}
+	price := h.Price()
 
 	// Check spending
-	canSpend, reason := ctx.Agent.CanSpend(price)
+	canSpend, reason := ctx.Agent.CanSpend(agent.CategoryCompute, price)
 	if !canSpend {
 		Reply(ctx, fmt.Sprintf("❌ Cannot summarize: %s", reason))
 		return nil
 	}
 
-	// Request payment
-	Reply(ctx, fmt.Sprintf("📄 Article summarization\nURL: %s\n\nThis service costs $%.2f.\nPay with: !pay %.2f USDT",
-		url, price, price))
+	log.Info("Summary requested", "url", pageURL, "user", ctx.Sender)
 
-	// After payment, would:
-	// 1. Fetch article
-	// 2. Send to OpenAI/LLM
-	// 3. Return summary
+	return requestPayment(ctx, "!summarize", []string{pageURL}, price,
+		fmt.Sprintf("📄 Article summarization\nURL: %s", pageURL))
+}
 
-	log.Info("Summary requested", "url", url, "user", ctx.Sender)
+// Fulfill fetches the article, summarizes it and posts the summary after payment
+func (h *SummarizeHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	if len(order.Args) == 0 {
+		return fmt.Errorf("order is missing the URL")
+	}
+	if !ctx.Agent.HasLLM() || h.Fetcher == nil {
+		return errServiceUnavailable
+	}
+
+	page, err := h.Fetcher.Fetch(context.Background(), order.Args[0])
+	if err != nil {
+		return fmt.Errorf("could not read the page: %w", err)
+	}
+
+	chunks := article.Chunk(page.Text, summaryChunkChars)
+	truncated := len(chunks) > maxSummaryChunks
+	if truncated {
+		chunks = chunks[:maxSummaryChunks]
+	}
+
+	summary, err := ctx.Agent.Summarize(context.Background(), page.Title, chunks)
+	if err != nil {
+		return err
+	}
+
+	Reply(ctx, formatSummary(page, summary, truncated))
+	log.Info("Summary delivered", "order", order.ID, "url", page.URL, "chunks", len(chunks), "user", order.Sender)
 	return nil
 }
 
+// formatSummary lays out a summary with its title, key points and source
+func formatSummary(page *article.Article, summary *agent.Summary, truncated bool) string {
+	title := page.Title
+	if title == "" {
+		title = "Summary"
+	}
+
+	msg := fmt.Sprintf("📄 %s\n\n%s\n\nKey points:", title, summary.Summary)
+	for _, point := range summary.KeyPoints {
+		msg += "\n• " + point
+	}
+	msg += "\n\n🔗 Source: " + page.URL
+	if truncated {
+		msg += "\n\n(The page is long, only its first part was summarized.)"
+	}
+	return msg
+}
+
 func (h *SummarizeHandler) Description() string {
 	return "Summarize any article or webpage"
 }
 
-func (h *SummarizeHandler) Price() float64 {
-	return 0.50
+func (h *SummarizeHandler) Price() money.Money {
+	return money.Dollars("0.50")
 }
 
 // ImageHandler - AI image generation ($0.75)
@@ -101,28 +189,24 @@ func (h *ImageHandler) Handle(ctx *Context) error {
 		return nil
 	}
 
-	prompt := strings.Join(parts[1:], " ")
-	price := 0.75
-
-	canSpend, reason := ctx.Agent.CanSpend(price)
-	if !canSpend {
-		Reply(ctx, fmt.Sprintf("❌ Cannot generate image: %s", reason))
-		return nil
-	}
-
-	Reply(ctx, fmt.Sprintf("🎨 AI Image Generation\nPrompt: %s\n\nThis service costs $%.2f.\nPay with: !pay %.2f USDT",
-		prompt, price, price))
-
-	log.Info("Image generation requested", "prompt", prompt, "user", ctx.Sender)
+	// No image provider is wired up yet, so nobody is invoiced for one
+	log.Info("Image generation requested but unavailable", "prompt", strings.Join(parts[1:], " "), "user", ctx.Sender)
+	Reply(ctx, fmt.Sprintf("❌ Cannot generate image: %v", errServiceUnavailable))
 	return nil
 }
 
+// Fulfill generates the image after payment. Orders placed before image
+// generation was switched off are refunded through the failure path.
+func (h *ImageHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	return errServiceUnavailable
+}
+
 func (h *ImageHandler) Description() string {
 	return "Generate AI images from text prompts"
 }
 
-func (h *ImageHandler) Price() float64 {
-	return 0.75
+func (h *ImageHandler) Price() money.Money {
+	return money.Dollars("0.75")
 }
 
 // CodeHandler - Code generation ($0.50)
@@ -136,18 +220,51 @@ func (h *CodeHandler) Handle(ctx *Context) error {
 	}
 
 	description := strings.Join(parts[1:], " ")
-	price := 0.50
+	if !ctx.Agent.HasLLM() {
+		Reply(ctx, fmt.Sprintf("❌ Cannot generate code: %v", errServiceUnavailable))
+		return nil
+	}
+	price := h.Price()
 
-	canSpend, reason := ctx.Agent.CanSpend(price)
+	canSpend, reason := ctx.Agent.CanSpend(agent.CategoryCompute, price)
 	if !canSpend {
 		Reply(ctx, fmt.Sprintf("❌ Cannot generate code: %s", reason))
 		return nil
 	}
 
-	Reply(ctx, fmt.Sprintf("💻 Code Generation\nDescription: %s\n\nThis service costs $%.2f.\nPay with: !pay %.2f USDT",
-		description, price, price))
-
 	log.Info("Code generation requested", "description", description, "user", ctx.Sender)
+
+	return requestPayment(ctx, "!code", parts[1:], price,
+		fmt.Sprintf("💻 Code Generation\nDescription: %s", description))
+}
+
+// codePrompt steers the LLM towards answers that fit in a chat message
+const codePrompt = `You are a senior software engineer writing code for a paying customer in a chat room.
+Answer with the code in a single fenced code block, followed by at most three short sentences on how to use it.
+Pick the language the request asks for, or the most fitting one if it does not say.`
+
+// Fulfill generates the code after payment
+func (h *CodeHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	if len(order.Args) == 0 {
+		return fmt.Errorf("order is missing the code description")
+	}
+
+	completion, err := ctx.Agent.Complete(context.Background(), agent.CompletionRequest{
+		Messages: []agent.Message{
+			{Role: agent.RoleSystem, Content: codePrompt},
+			{Role: agent.RoleUser, Content: strings.Join(order.Args, " ")},
+		},
+		MaxTokens: 2000,
+	})
+	if errors.Is(err, agent.ErrNoLLM) {
+		return errServiceUnavailable
+	}
+	if err != nil {
+		return fmt.Errorf("code generation failed: %w", err)
+	}
+
+	Reply(ctx, fmt.Sprintf("💻 Here is your code (order %s):\n\n%s", order.ID, completion.Content))
+	log.Info("Code delivered", "order", order.ID, "user", order.Sender)
 	return nil
 }
 
@@ -155,11 +272,12 @@ func (h *CodeHandler) Description() string {
 	return "Generate code snippets from description"
 }
 
-func (h *CodeHandler) Price() float64 {
-	return 0.50
+func (h *CodeHandler) Price() money.Money {
+	return money.Dollars("0.50")
 }
 
-// ProposeHandler - Agent proposes custom service
+// ProposeHandler - Agent proposes custom service. The quote stays open
+// for the sender in ctx.Proposals until they answer or it expires.
 type ProposeHandler struct{}
 
 func (h *ProposeHandler) Handle(ctx *Context) error {
@@ -170,30 +288,68 @@ func (h *ProposeHandler) Handle(ctx *Context) error {
 	}
 
 	idea := strings.Join(parts[1:], " ")
+	// Without an LLM the quote would be a fallback and the job could never run
+	if !ctx.Agent.HasLLM() {
+		Reply(ctx, fmt.Sprintf("❌ Cannot propose a custom service: %v", errServiceUnavailable))
+		return nil
+	}
 
 	// Get AI pricing recommendation
-	price, reasoning, err := ctx.Agent.DecideServicePricing(nil, idea)
+	est, err := ctx.Agent.EstimatePrice(context.Background(), idea)
 	if err != nil {
-		price = 5.00 // Default
-		reasoning = "Based on complexity estimate"
+		log.Error("Failed to price custom service", "idea", idea, "error", err)
+		Reply(ctx, "⚠️ I could not price that right now. Please try again.")
+		return err
 	}
 
+	proposal := &Proposal{Idea: idea, Price: est.Price, Floor: est.Floor}
+	ctx.Proposals.Put(ctx, proposal)
+
 	msg := fmt.Sprintf(`🤖 **Custom Service Proposal**
 
 Your request: %s
 
-**Recommended price:** $%.2f
+**Recommended price:** %s
 **Reasoning:** %s
 
-Would you like me to proceed? Reply:
+Would you like me to proceed? Reply before %s:
 • "yes" to confirm and receive payment instructions
 • "no" to cancel
-• Or suggest a different price`,
-		idea, price, reasoning)
+• Or suggest a different price, e.g. "how about $0.40?"`,
+		idea, est.Price, est.Reasoning(), proposal.ExpiresAt.UTC().Format("Jan 02 15:04 MST"))
 
 	ReplyWithHTML(ctx, msg)
 
-	log.Info("Custom service proposed", "idea", idea, "price", price, "user", ctx.Sender)
+	log.Info("Custom service proposed", "idea", idea, "price", est.Price, "floor", est.Floor, "user", ctx.Sender)
+	return nil
+}
+
+// proposePrompt has the LLM deliver an accepted custom service
+const proposePrompt = `You are an autonomous agent delivering a custom service a customer paid for in a chat room.
+Do the work they asked for as completely as a single chat message allows. Use fenced code blocks for code.`
+
+// Fulfill delivers the custom service after payment
+func (h *ProposeHandler) Fulfill(ctx *Context, order *orders.Order) error {
+	if len(order.Args) == 0 {
+		return fmt.Errorf("order is missing the service request")
+	}
+
+	completion, err := ctx.Agent.Complete(context.Background(), agent.CompletionRequest{
+		Messages: []agent.Message{
+			{Role: agent.RoleSystem, Content: proposePrompt},
+			{Role: agent.RoleUser, Content: strings.Join(order.Args, " ")},
+		},
+		MaxTokens: 3000,
+	})
+	if errors.Is(err, agent.ErrNoLLM) {
+		return errServiceUnavailable
+	}
+	if err != nil {
+		return fmt.Errorf("custom service failed: %w", err)
+	}
+
+	Reply(ctx, fmt.Sprintf("🤖 Here is your custom service (order %s):\n\n%s", order.ID, completion.Content))
+	log.Info("Custom service delivered", "order", order.ID, "user", order.Sender)
 	return nil
 }
 
@@ -201,8 +357,8 @@ func (h *ProposeHandler) Description() string {
 	return "Agent proposes custom service pricing"
 }
 
-func (h *ProposeHandler) Price() float64 {
-	return -1 // Variable
+func (h *ProposeHandler) Price() money.Money {
+	return money.Dollars("-1") // Variable
 }
 
 // ServicesHandler lists all services
@@ -218,15 +374,9 @@ func (h *ServicesHandler) Handle(ctx *Context) error {
 • !price <crypto> - Get crypto prices
 
 **Paid Services:**
-• !alert <crypto> <price> - $0.10
-  Set price alerts for any cryptocurrency
-
 • !summarize <url> - $0.50
   Get AI summary of any article
 
-• !image <prompt> - $0.75
-  Generate AI images (DALL-E style)
-
 • !code <description> - $0.50
   Generate code in any language
 
@@ -235,7 +385,10 @@ func (h *ServicesHandler) Handle(ctx *Context) error {
 
 💡 **My limits:** $1 per transaction, $5 per day
 
-All payments in USDT or USDC. Type !pay to send payment.`
+Paid services send you an invoice and run as soon as it is confirmed.
+
+**Accepted currencies:**
+` + ctx.Payments.currencyList()
 
 	ReplyWithHTML(ctx, services)
 	return nil
@@ -245,8 +398,8 @@ func (h *ServicesHandler) Description() string {
 	return "List all available services"
 }
 
-func (h *ServicesHandler) Price() float64 {
-	return 0
+func (h *ServicesHandler) Price() money.Money {
+	return money.Money{}
 }
 
 // PriceHandler gets crypto prices
@@ -272,6 +425,6 @@ func (h *PriceHandler) Description() string {
 	return "Get cryptocurrency prices"
 }
 
-func (h *PriceHandler) Price() float64 {
-	return 0
+func (h *PriceHandler) Price() money.Money {
+	return money.Money{}
 }
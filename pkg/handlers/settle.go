@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/shkeeper"
+)
+
+var (
+	errAlreadySettled = errors.New("order already settled")
+	errNotCancellable = errors.New("order can no longer be cancelled")
+)
+
+// settle books what SHKeeper actually received for an order.
+// Exact and overpaid orders are paid; underpaid orders get a top-up
+// invoice for the difference and are paid once the top-up arrives.
+// Late payments on expired orders are settled the same way, while
+// payments on cancelled orders are booked and refunded, minus whatever
+// was already refunded when the order was cancelled.
+func (p *Payments) settle(order *orders.Order, status *shkeeper.PaymentStatus) {
+	received := status.ReceivedAmount()
+	if received.IsZero() && status.Status == shkeeper.StatusConfirmed {
+		// SHKeeper leaves received empty when exactly the invoice amount arrived
+		received = status.Invoiced()
+	}
+	price := order.Amount()
+	mismatched := received.Currency != order.Currency
+	short := !mismatched && received.Cmp(price) < 0
+	cancelled := false
+	// On cancelled orders, what arrived since the last callback and what
+	// is still owed back on top of earlier refunds
+	var arrived, owed money.Decimal
+
+	// Replayed callbacks find the order settled and book nothing twice
+	settled, err := p.Orders.Update(order.ID, func(o *orders.Order) error {
+		if !o.State.Settleable() {
+			return errAlreadySettled
+		}
+		if mismatched {
+			o.State = orders.StateFailed
+			o.Error = fmt.Sprintf("received %s for a %s invoice", received, o.Currency)
+			return nil
+		}
+		if o.State == orders.StateCancelled {
+			// Received is cumulative, so a repeated callback brings nothing new
+			if received.Amount.Cmp(o.Received) <= 0 {
+				return errAlreadySettled
+			}
+			cancelled = true
+			arrived = received.Amount.Sub(o.Received)
+			owed = received.Amount.Sub(o.Refunded)
+			o.Received = received.Amount
+			o.Refunded = received.Amount
+			o.Late = true
+			return nil
+		}
+		o.Received = received.Amount
+		o.Late = o.State != orders.StatePending
+		if short {
+			o.State = orders.StateUnderpaid
+			return nil
+		}
+		o.State = orders.StatePaid
+		o.Excess = received.Sub(price).Amount
+		return nil
+	})
+	if errors.Is(err, errAlreadySettled) {
+		return
+	}
+	if err != nil {
+		log.Error("Failed to settle order", "order", order.ID, "error", err)
+		return
+	}
+	if mismatched {
+		log.Error("Payment currency does not match order",
+			"order", settled.ID, "expected", settled.Currency, "received", received)
+		p.earn(settled, received, money.Money{}, fmt.Sprintf("Mismatched payment for order %s", settled.ID))
+		p.refund(settled, received, "paid in the wrong currency")
+		return
+	}
+
+	if cancelled {
+		// Only the new part is booked; the rest was booked and refunded before
+		paid := money.New(arrived, settled.Currency)
+		usd, _ := settled.USDValue(paid)
+		p.earn(settled, paid, usd, fmt.Sprintf("Payment for cancelled order %s", settled.ID))
+		log.Warn("Payment received for cancelled order", "order", settled.ID, "received", received, "new", paid)
+
+		Reply(p.contextFor(settled), fmt.Sprintf("💸 Received %s for cancelled order %s.", paid, settled.ID))
+		if owed.Sign() > 0 {
+			p.refund(settled, money.New(owed, settled.Currency), "payment for cancelled order")
+		}
+		return
+	}
+
+	// The ledger always records what arrived, never what was invoiced
+	usd, _ := settled.USDValue(received)
+	p.earn(settled, received, usd, p.earnDescription(settled, price, received))
+
+	if settled.Late {
+		log.Warn("Late payment received", "order", settled.ID, "received", received, "state", settled.State)
+	}
+
+	ctx := p.contextFor(settled)
+	switch {
+	case short:
+		p.requestTopUp(settled, price.Sub(received))
+		return
+
+	case settled.Excess.Sign() > 0:
+		log.Warn("Order overpaid", "order", settled.ID, "excess", money.New(settled.Excess, settled.Currency))
+		Reply(ctx, fmt.Sprintf("✅ Payment confirmed!\nOrder: %s\n\nYou sent %s, which is %s more than needed.",
+			settled.ID, received, money.New(settled.Excess, settled.Currency)))
+		// Overpayments go to credits where possible, saving an on-chain refund
+		excess := money.New(settled.Excess, settled.Currency)
+		if !p.credit(settled, excess, "overpayment") {
+			p.refund(settled, excess, "overpayment")
+		}
+
+	case settled.Late:
+		Reply(ctx, fmt.Sprintf("✅ Late payment received!\nOrder: %s\nThe invoice had expired, but your payment arrived and the order goes ahead.", settled.ID))
+
+	default:
+		Reply(ctx, fmt.Sprintf("✅ Payment confirmed!\nOrder: %s\nThank you!", settled.ID))
+	}
+
+	if settled.ParentID != "" {
+		p.completeParent(settled)
+		return
+	}
+	p.fulfill(settled)
+}
+
+// requestTopUp invoices the sender for what is still missing on an underpaid order
+func (p *Payments) requestTopUp(order *orders.Order, missing money.Money) {
+	ctx := p.contextFor(order)
+
+	topUp, err := p.open(&orders.Order{
+		ID:       uuid.New().String(),
+		Sender:   order.Sender,
+		RoomID:   order.RoomID,
+		Service:  order.Service,
+		Price:    missing.Amount,
+		Currency: missing.Currency,
+		Quote:    order.Quote,
+		ParentID: order.ID,
+	})
+	if err != nil {
+		log.Error("Failed to create top-up invoice", "order", order.ID, "error", err)
+		Reply(ctx, fmt.Sprintf("⚠️ Received %s of %s for order %s, but I could not create an invoice for the rest. An admin will follow up.",
+			order.Amount().Sub(missing), order.Amount(), order.ID))
+		return
+	}
+
+	if _, err := p.Orders.Update(order.ID, func(o *orders.Order) error {
+		o.TopUpID = topUp.ID
+		return nil
+	}); err != nil {
+		log.Error("Failed to link top-up order", "order", order.ID, "top_up", topUp.ID, "error", err)
+	}
+
+	Reply(ctx, fmt.Sprintf("⚠️ Partial payment\n\nReceived %s of %s for order %s.\nPlease pay the remaining %s here: %s\n%s\n\nTop-up order: %s",
+		order.Amount().Sub(missing), order.Amount(), order.ID, missing, topUp.PaymentURL, p.expiresIn(topUp), topUp.ID))
+}
+
+// completeParent marks an underpaid order paid once its top-up is settled,
+// and the top-up fulfilled, since its payment went to the order
+func (p *Payments) completeParent(topUp *orders.Order) {
+	// Set when the parent was completed by this top-up before a restart
+	applied := false
+	parent, err := p.Orders.Update(topUp.ParentID, func(o *orders.Order) error {
+		if o.State != orders.StateUnderpaid {
+			applied = o.TopUpID == topUp.ID && (o.State == orders.StatePaid || o.State == orders.StateFulfilled)
+			return errAlreadySettled
+		}
+		o.State = orders.StatePaid
+		// Any top-up overpayment is refunded on its own
+		o.Received = o.Received.Add(topUp.Received.Sub(topUp.Excess))
+		return nil
+	})
+	if errors.Is(err, errAlreadySettled) && !applied {
+		// A late top-up for an order that was cancelled in the meantime
+		p.fail(topUp, fmt.Errorf("order %s was already closed", topUp.ParentID))
+		p.refund(topUp, money.New(topUp.Received.Sub(topUp.Excess), topUp.Currency), "top-up for a closed order")
+		return
+	}
+	if err != nil && !applied {
+		// The top-up stays paid, so Resume tries again
+		log.Error("Failed to complete underpaid order", "order", topUp.ParentID, "top_up", topUp.ID, "error", err)
+		return
+	}
+
+	if _, _, err := p.Orders.Transition(topUp.ID, orders.StateFulfilled, orders.StatePaid); err != nil {
+		log.Error("Failed to mark top-up fulfilled", "order", topUp.ID, "error", err)
+	}
+	if applied {
+		// Resume delivers the parent itself if it is still paid
+		return
+	}
+	if parent.ParentID != "" {
+		p.completeParent(parent)
+		return
+	}
+	p.fulfill(parent)
+}
+
+// earn books money that arrived for an order. Top-ups are left out, as their
+// credits are booked under the services they pay for.
+func (p *Payments) earn(order *orders.Order, received, usd money.Money, description string) {
+	if order.Service == topupService {
+		log.Info("Credits payment received", "order", order.ID, "received", received)
+		return
+	}
+	p.Agent.RecordEarn(order.Service, received, usd, description)
+}
+
+func (p *Payments) earnDescription(order *orders.Order, price, received money.Money) string {
+	desc := fmt.Sprintf("Payment for %s (order %s)", order.Service, order.ID)
+	if order.ParentID != "" {
+		desc = fmt.Sprintf("Top-up for order %s (order %s)", order.ParentID, order.ID)
+	}
+
+	switch received.Cmp(price) {
+	case -1:
+		desc += fmt.Sprintf(", underpaid %s of %s", received, price)
+	case 1:
+		desc += fmt.Sprintf(", overpaid by %s", received.Sub(price))
+	}
+	return desc
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maunium.net/go/mautrix"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+	"clawclack/pkg/orders"
+	"clawclack/pkg/refunds"
+	"clawclack/pkg/shkeeper"
+)
+
+// newTestPayments returns Payments backed by a temporary order store and a
+// fake server that plays both the homeserver and SHKeeper
+func newTestPayments(t *testing.T) *Payments {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v1/invoice":
+			w.Write([]byte(`{"payment_url": "https://pay.example/top-up"}`))
+		case strings.HasPrefix(r.URL.Path, "/api/v1/payment/"):
+			// Keeps watchers of new top-up orders waiting
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte(`{"event_id": "$event"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := mautrix.NewClient(server.URL, "@bot:example.org", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := orders.Open(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	refundStore, err := refunds.Open(filepath.Join(t.TempDir(), "refunds.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { refundStore.Close() })
+
+	a := agent.New(agent.Config{})
+	return &Payments{
+		Client:   client,
+		SHKeeper: shkeeper.New(server.URL, "key"),
+		Agent:    a,
+		Orders:   store,
+		Refunds:  &Refunds{Client: client, Agent: a, Store: refundStore},
+	}
+}
+
+// refundedFor returns the total queued for refund on an order
+func refundedFor(t *testing.T, p *Payments, orderID string) string {
+	t.Helper()
+	queued, err := p.Refunds.Store.List(func(rf *refunds.Refund) bool { return rf.OrderID == orderID })
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := money.Zero
+	for _, rf := range queued {
+		total = total.Add(rf.Amount)
+	}
+	return total.String()
+}
+
+func TestSettle(t *testing.T) {
+	d := money.MustParseDecimal
+	tests := []struct {
+		name     string
+		state    orders.State
+		received money.Decimal // already received before this payment
+		refunded money.Decimal
+		status   shkeeper.PaymentStatus
+
+		wantState    orders.State
+		wantReceived string
+		wantRefunded string
+		wantExcess   string
+		wantLate     bool
+		wantTopUp    bool
+		wantEarned   string // USD booked by this payment
+	}{
+		{
+			name:      "exact payment",
+			state:     orders.StatePending,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StateFulfilled, wantReceived: "10", wantRefunded: "0", wantExcess: "0", wantEarned: "$10.00",
+		},
+		{
+			name:      "confirmed without a received amount",
+			state:     orders.StatePending,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Amount: d("10"), Currency: "USDT"},
+			wantState: orders.StateFulfilled, wantReceived: "10", wantRefunded: "0", wantExcess: "0", wantEarned: "$10.00",
+		},
+		{
+			name:      "overpaid",
+			state:     orders.StatePending,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("12.5"), Currency: "USDT"},
+			wantState: orders.StateFulfilled, wantReceived: "12.5", wantRefunded: "0", wantExcess: "2.5", wantEarned: "$12.50",
+		},
+		{
+			name:      "underpaid",
+			state:     orders.StatePending,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusPartial, Received: d("4"), Currency: "USDT"},
+			wantState: orders.StateUnderpaid, wantReceived: "4", wantRefunded: "0", wantExcess: "0", wantTopUp: true, wantEarned: "$4.00",
+		},
+		{
+			name:      "late payment on an expired order",
+			state:     orders.StateExpired,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusExpired, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StateFulfilled, wantReceived: "10", wantRefunded: "0", wantExcess: "0", wantLate: true, wantEarned: "$10.00",
+		},
+		{
+			name:      "wrong currency",
+			state:     orders.StatePending,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDC"},
+			wantState: orders.StateFailed, wantReceived: "0", wantRefunded: "0", wantExcess: "0", wantEarned: "$10.00",
+		},
+		{
+			name:      "replayed callback on a paid order",
+			state:     orders.StatePaid,
+			received:  d("10"),
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StatePaid, wantReceived: "10", wantRefunded: "0", wantExcess: "0", wantEarned: "$0.00",
+		},
+		{
+			name:      "wrong currency on a fulfilled order",
+			state:     orders.StateFulfilled,
+			received:  d("10"),
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDC"},
+			wantState: orders.StateFulfilled, wantReceived: "10", wantRefunded: "0", wantExcess: "0", wantEarned: "$0.00",
+		},
+		{
+			name:      "payment on a cancelled order",
+			state:     orders.StateCancelled,
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StateCancelled, wantReceived: "10", wantRefunded: "10", wantExcess: "0", wantLate: true, wantEarned: "$10.00",
+		},
+		{
+			name:      "more arrives on a cancelled order",
+			state:     orders.StateCancelled,
+			received:  d("4"),
+			refunded:  d("4"),
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StateCancelled, wantReceived: "10", wantRefunded: "10", wantExcess: "0", wantLate: true, wantEarned: "$6.00",
+		},
+		{
+			name:      "replayed callback on a cancelled order",
+			state:     orders.StateCancelled,
+			received:  d("10"),
+			refunded:  d("10"),
+			status:    shkeeper.PaymentStatus{Status: shkeeper.StatusConfirmed, Received: d("10"), Currency: "USDT"},
+			wantState: orders.StateCancelled, wantReceived: "10", wantRefunded: "10", wantExcess: "0", wantEarned: "$0.00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPayments(t)
+			order := &orders.Order{
+				ID:       "order-1",
+				Sender:   "@alice:example.org",
+				RoomID:   "!room:example.org",
+				Service:  "!pay",
+				Price:    d("10"),
+				Currency: "USDT",
+				Received: tt.received,
+				Refunded: tt.refunded,
+				State:    tt.state,
+			}
+			if err := p.Orders.Create(order); err != nil {
+				t.Fatal(err)
+			}
+
+			status := tt.status
+			status.OrderID = order.ID
+			p.settle(order, &status)
+
+			got, err := p.Orders.Get(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != tt.wantState {
+				t.Errorf("state = %s, want %s", got.State, tt.wantState)
+			}
+			if got.Received.String() != tt.wantReceived {
+				t.Errorf("received = %s, want %s", got.Received, tt.wantReceived)
+			}
+			if got.Refunded.String() != tt.wantRefunded {
+				t.Errorf("refunded = %s, want %s", got.Refunded, tt.wantRefunded)
+			}
+			if got.Excess.String() != tt.wantExcess {
+				t.Errorf("excess = %s, want %s", got.Excess, tt.wantExcess)
+			}
+			if got.Late != tt.wantLate {
+				t.Errorf("late = %v, want %v", got.Late, tt.wantLate)
+			}
+			if (got.TopUpID != "") != tt.wantTopUp {
+				t.Errorf("top-up = %q, want one %v", got.TopUpID, tt.wantTopUp)
+			}
+			if earned := p.Agent.GetSpendingStats().EarnedTotal.String(); earned != tt.wantEarned {
+				t.Errorf("earned = %s, want %s", earned, tt.wantEarned)
+			}
+		})
+	}
+}
+
+func TestSettleBooksReplaysOnce(t *testing.T) {
+	p := newTestPayments(t)
+	order := &orders.Order{ID: "order-1", Service: "!pay", Price: money.MustParseDecimal("10"), Currency: "USDT"}
+	if err := p.Orders.Create(order); err != nil {
+		t.Fatal(err)
+	}
+
+	status := &shkeeper.PaymentStatus{OrderID: order.ID, Status: shkeeper.StatusConfirmed, Received: money.MustParseDecimal("10"), Currency: "USDT"}
+	for i := 0; i < 3; i++ {
+		p.settle(order, status)
+	}
+
+	if earned := p.Agent.GetSpendingStats().EarnedTotal.String(); earned != "$10.00" {
+		t.Errorf("earned = %s after replays, want $10.00", earned)
+	}
+}
+
+func TestSettleTopUpCompletesParent(t *testing.T) {
+	p := newTestPayments(t)
+	d := money.MustParseDecimal
+	parent := &orders.Order{ID: "parent", Service: "!pay", Price: d("10"), Currency: "USDT"}
+	if err := p.Orders.Create(parent); err != nil {
+		t.Fatal(err)
+	}
+	p.settle(parent, &shkeeper.PaymentStatus{OrderID: parent.ID, Status: shkeeper.StatusPartial, Received: d("4"), Currency: "USDT"})
+
+	parent, err := p.Orders.Get(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topUp, err := p.Orders.Get(parent.TopUpID)
+	if err != nil {
+		t.Fatalf("top-up order: %v", err)
+	}
+	if topUp.Amount().String() != "6 USDT" || topUp.ParentID != parent.ID {
+		t.Fatalf("top-up = %s for %q, want 6 USDT for %q", topUp.Amount(), topUp.ParentID, parent.ID)
+	}
+
+	p.settle(topUp, &shkeeper.PaymentStatus{OrderID: topUp.ID, Status: shkeeper.StatusConfirmed, Received: d("6"), Currency: "USDT"})
+
+	parent, err = p.Orders.Get(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.State != orders.StateFulfilled || parent.Received.String() != "10" {
+		t.Errorf("parent = %s with %s received, want fulfilled with 10", parent.State, parent.Received)
+	}
+	if topUp, _ = p.Orders.Get(topUp.ID); topUp.State != orders.StateFulfilled {
+		t.Errorf("top-up = %s, want fulfilled", topUp.State)
+	}
+	if earned := p.Agent.GetSpendingStats().EarnedTotal.String(); earned != "$10.00" {
+		t.Errorf("earned = %s, want $10.00", earned)
+	}
+}
+
+type failingFulfiller struct{}
+
+func (failingFulfiller) Handle(ctx *Context) error { return nil }
+func (failingFulfiller) Description() string       { return "always fails" }
+func (failingFulfiller) Price() money.Money        { return money.Dollars("10") }
+func (failingFulfiller) Fulfill(ctx *Context, order *orders.Order) error {
+	return errors.New("out of order")
+}
+
+func TestFailedFulfillmentRefundsWhatIsOwed(t *testing.T) {
+	d := money.MustParseDecimal
+	tests := []struct {
+		name         string
+		received     string
+		wantRefunded string // over all refunds for the order
+	}{
+		{"exact payment", "10", "10"},
+		{"overpaid", "12.5", "12.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPayments(t)
+			p.Handlers = NewRegistry()
+			p.Handlers.Register("!broken", failingFulfiller{})
+
+			order := &orders.Order{ID: "order-1", Service: "!broken", Price: d("10"), Currency: "USDT"}
+			if err := p.Orders.Create(order); err != nil {
+				t.Fatal(err)
+			}
+			p.settle(order, &shkeeper.PaymentStatus{OrderID: order.ID, Status: shkeeper.StatusConfirmed, Received: d(tt.received), Currency: "USDT"})
+
+			got, err := p.Orders.Get(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != orders.StateFailed {
+				t.Errorf("state = %s, want failed", got.State)
+			}
+			if refunded := refundedFor(t, p, order.ID); refunded != tt.wantRefunded {
+				t.Errorf("refunded %s, want %s", refunded, tt.wantRefunded)
+			}
+
+			// A second failure finds nothing left to refund
+			p.fulfill(got)
+			if refunded := refundedFor(t, p, order.ID); refunded != tt.wantRefunded {
+				t.Errorf("refunded %s after a repeat, want %s", refunded, tt.wantRefunded)
+			}
+		})
+	}
+}
+
+func TestResumedTopUpIsNotAppliedTwice(t *testing.T) {
+	p := newTestPayments(t)
+	d := money.MustParseDecimal
+	// The restart came after the parent was completed but before the top-up
+	// was marked fulfilled
+	parent := &orders.Order{ID: "parent", Service: "!pay", Price: d("10"), Currency: "USDT", Received: d("10"), TopUpID: "top-up", State: orders.StatePaid}
+	topUp := &orders.Order{ID: "top-up", Service: "!pay", Price: d("6"), Currency: "USDT", Received: d("6"), ParentID: "parent", State: orders.StatePaid}
+	for _, o := range []*orders.Order{parent, topUp} {
+		if err := p.Orders.Create(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.fulfill(topUp)
+
+	if got, _ := p.Orders.Get(topUp.ID); got.State != orders.StateFulfilled {
+		t.Errorf("top-up = %s, want fulfilled", got.State)
+	}
+	if got, _ := p.Orders.Get(parent.ID); got.Received.String() != "10" {
+		t.Errorf("parent received %s, want 10", got.Received)
+	}
+	if refunded := refundedFor(t, p, topUp.ID); refunded != "0" {
+		t.Errorf("refunded %s for the top-up, want nothing", refunded)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"clawclack/pkg/agent"
+	"clawclack/pkg/money"
+	"clawclack/pkg/payees"
+	"clawclack/pkg/shkeeper"
+)
+
+// errPaymentUnknown wraps payout errors after which the payment may or may
+// not have been sent. The reservation is kept so the budget is not spent twice.
+var errPaymentUnknown = errors.New("payment outcome unknown")
+
+// errPaymentInFlight is returned for a spend that is already being paid
+var errPaymentInFlight = errors.New("payment already in progress")
+
+// Spends sends the payments of the agent's spends from the SHKeeper wallet.
+// Approvals reserves the budget first and commits or rolls it back
+// depending on what Execute returns.
+type Spends struct {
+	SHKeeper *shkeeper.Client
+
+	mu      sync.Mutex
+	sending map[string]bool // transaction IDs with a payout in flight
+}
+
+// Execute pays a reserved spend. The transaction ID is the idempotency key,
+// so an admin's !spend retry after a timeout never pays twice. Execute runs
+// on the Matrix event handler, so it tries once: errors wrapping
+// errPaymentUnknown mean the payment may have gone out and an admin settles it.
+func (s *Spends) Execute(tx *agent.Transaction) error {
+	if tx.Payee == "" {
+		return fmt.Errorf("spend %s has no payee", tx.ID)
+	}
+	if tx.Amount.Currency == money.USD {
+		return fmt.Errorf("cannot send %s on-chain, spend a stablecoin instead", tx.Amount)
+	}
+
+	s.mu.Lock()
+	if s.sending == nil {
+		s.sending = make(map[string]bool)
+	}
+	if s.sending[tx.ID] {
+		s.mu.Unlock()
+		return errPaymentInFlight
+	}
+	s.sending[tx.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sending, tx.ID)
+		s.mu.Unlock()
+	}()
+
+	err := s.SHKeeper.SendPayment(context.Background(), tx.Amount, tx.Payee, "spend-"+tx.ID)
+	if err == nil {
+		log.Info("📤 Spend paid", "transaction", tx.ID, "amount", tx.Amount, "payee", tx.Payee)
+		return nil
+	}
+	if notSent(err) {
+		return err
+	}
+	log.Warn("Spend payout outcome unknown", "transaction", tx.ID, "error", err)
+	return fmt.Errorf("%w: %v", errPaymentUnknown, err)
+}
+
+// notSent reports whether a payout error proves nothing was sent
+func notSent(err error) bool {
+	var payoutErr *shkeeper.PayoutError
+	if errors.As(err, &payoutErr) {
+		return payoutErr.Rejected()
+	}
+	// A request that never connected cannot have paid anything
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// SpendsHandler lets admins have the agent pay a payee and settle spends
+// whose payment outcome is unknown
+type SpendsHandler struct{}
+
+func (h *SpendsHandler) Handle(ctx *Context) error {
+	if !ctx.IsAdmin {
+		Reply(ctx, "❌ Only admins can manage spends.")
+		return nil
+	}
+
+	parts := strings.Fields(ctx.Message)
+	if len(parts) >= 2 && parts[1] == "pay" {
+		return h.pay(ctx, parts[2:])
+	}
+	if len(parts) < 3 {
+		return h.list(ctx)
+	}
+
+	txID := parts[2]
+	switch parts[1] {
+	case "retry":
+		tx, ok := ctx.Agent.Transaction(txID)
+		if !ok || tx.Status != agent.StatusReserved {
+			Reply(ctx, fmt.Sprintf("❌ Spend %s is not waiting for its payment", txID))
+			return nil
+		}
+		Reply(ctx, fmt.Sprintf("🔁 Retrying payment of spend %s...", txID))
+		paid, err := ctx.Approvals.execute(tx)
+		if errors.Is(err, errPaymentInFlight) {
+			Reply(ctx, fmt.Sprintf("⏳ Spend %s is already being paid", txID))
+		} else if err == nil {
+			Reply(ctx, fmt.Sprintf("💸 Spend %s paid: %s to %s", paid.ID, paid.Amount, paid.Payee))
+		}
+	case "sent":
+		// Only for payments an admin found in SHKeeper
+		tx, err := ctx.Agent.Commit(txID)
+		if err != nil {
+			Reply(ctx, fmt.Sprintf("❌ Could not settle spend %s: %v", txID, err))
+			return nil
+		}
+		Reply(ctx, fmt.Sprintf("✅ Spend %s of %s to %s recorded as paid", tx.ID, tx.Amount, tx.Payee))
+	case "release":
+		// Only for payments an admin checked were never sent
+		tx, err := ctx.Agent.Rollback(txID, errors.New("released by "+ctx.Sender.String()))
+		if err != nil {
+			Reply(ctx, fmt.Sprintf("❌ Could not release spend %s: %v", txID, err))
+			return nil
+		}
+		Reply(ctx, fmt.Sprintf("🔓 Released the %s reserved for spend %s", tx.Amount, tx.ID))
+	default:
+		Reply(ctx, "Usage: !spends | !spend pay <payee> <amount> <currency> <category> [description] | !spend retry|sent|release <transaction_id>")
+	}
+	return nil
+}
+
+// pay has the agent pay a payee from the address book. It goes through
+// Approvals.Spend like every spend, so the limits, approvals and budget
+// alerts apply to admins too.
+func (h *SpendsHandler) pay(ctx *Context, args []string) error {
+	if len(args) < 4 {
+		Reply(ctx, "Usage: !spend pay <payee> <amount> <currency> <category> [description]\nExample: !spend pay designer 0.50 USDT marketing Launch banner")
+		return nil
+	}
+
+	payee, err := ctx.Payees.Get(args[0])
+	if errors.Is(err, payees.ErrNotFound) {
+		Reply(ctx, fmt.Sprintf("❌ No payee named %s. Add it first with: !payee add <name> <network> <address>", args[0]))
+		return nil
+	}
+	if err != nil {
+		log.Error("Failed to load payee", "name", args[0], "error", err)
+		Reply(ctx, "⚠️ Failed to load the address book.")
+		return err
+	}
+
+	currency, ok := ctx.Payments.resolveCurrency(ctx, args[2])
+	if !ok {
+		return nil
+	}
+	amount, err := money.Parse(args[1], currency)
+	if err != nil || amount.Sign() <= 0 {
+		Reply(ctx, fmt.Sprintf("❌ Invalid amount %q. Example: !spend pay designer 0.50 USDT marketing", args[1]))
+		return nil
+	}
+
+	category := strings.ToLower(args[3])
+	description := "Payment to " + payee.Name
+	if len(args) > 4 {
+		description = strings.Join(args[4:], " ")
+	}
+
+	log.Info("Spend requested", "payee", payee.Name, "amount", amount, "category", category, "by", ctx.Sender)
+	tx, err := ctx.Approvals.Spend(category, amount, payee.Address, description)
+	switch {
+	case tx == nil:
+		Reply(ctx, fmt.Sprintf("❌ %v", err))
+	case tx.Status == agent.StatusPending:
+		Reply(ctx, fmt.Sprintf("🛂 Spend %s of %s to %s is over the limits and waits for approval in the admin room.", tx.ID, amount, payee.Name))
+	case err != nil:
+		Reply(ctx, fmt.Sprintf("⚠️ Spend %s of %s to %s was not completed: %v", tx.ID, amount, payee.Name, err))
+	default:
+		Reply(ctx, fmt.Sprintf("💸 Spend %s paid: %s to %s (%s)", tx.ID, tx.Amount, payee.Name, tx.Payee))
+	}
+	return nil
+}
+
+// list shows spends that still hold budget without being paid
+func (h *SpendsHandler) list(ctx *Context) error {
+	reserved := ctx.Agent.Reservations()
+	if len(reserved) == 0 {
+		Reply(ctx, "✅ No spends are waiting for their payment.")
+		return nil
+	}
+
+	msg := "🔒 Spends waiting for their payment\n"
+	for _, tx := range reserved {
+		msg += fmt.Sprintf("\n• %s: %s to %s (%s), reserved %s", tx.ID, tx.Amount, tx.Payee, tx.Description, tx.Timestamp.Format("Jan 02 15:04"))
+	}
+	msg += "\n\nCheck SHKeeper first, then:\n• Record a payment that went out with: !spend sent <id>\n• Send it again with: !spend retry <id>\n• Release the budget of a payment that was never sent with: !spend release <id>"
+	Reply(ctx, msg)
+	return nil
+}
+
+func (h *SpendsHandler) Description() string {
+	return "Pay a payee, or retry or release unsettled spends (admins only)"
+}
+
+func (h *SpendsHandler) Price() money.Money {
+	return money.Money{}
+}
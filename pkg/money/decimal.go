@@ -0,0 +1,270 @@
+package money
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Scale is the number of fractional digits a Decimal keeps.
+// 18 covers every asset we handle (ETH and most ERC-20 tokens use 18).
+const Scale = 18
+
+var scaleFactor = new(big.Int).Exp(big.NewInt(10), big.NewInt(Scale), nil)
+
+// Decimal is an exact fixed-point number with Scale fractional digits.
+// The zero value is 0. Decimals are immutable; every operation returns a new value.
+type Decimal struct {
+	v *big.Int // value * 10^Scale, nil means zero
+}
+
+// Zero is the zero Decimal
+var Zero = Decimal{}
+
+// NewFromInt returns the Decimal for a whole number
+func NewFromInt(i int64) Decimal {
+	v := big.NewInt(i)
+	return Decimal{v: v.Mul(v, scaleFactor)}
+}
+
+// ParseDecimal parses a plain decimal string such as "12", "-0.5" or "0.000001".
+// Exponents, thousands separators and more than Scale fractional digits are rejected.
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return Decimal{}, fmt.Errorf("invalid amount %q: empty", s)
+	}
+
+	neg := false
+	switch str[0] {
+	case '-':
+		neg = true
+		str = str[1:]
+	case '+':
+		str = str[1:]
+	}
+
+	whole, frac, _ := strings.Cut(str, ".")
+	if whole == "" && frac == "" {
+		return Decimal{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Decimal{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > Scale {
+		return Decimal{}, fmt.Errorf("invalid amount %q: more than %d decimal places", s, Scale)
+	}
+
+	digits := whole + frac + strings.Repeat("0", Scale-len(frac))
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		v.Neg(v)
+	}
+	return Decimal{v: v}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics on error.
+// Use it only for constants.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) int() *big.Int {
+	if d.v == nil {
+		return new(big.Int)
+	}
+	return d.v
+}
+
+// Add returns d + o
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{v: new(big.Int).Add(d.int(), o.int())}
+}
+
+// Sub returns d - o
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{v: new(big.Int).Sub(d.int(), o.int())}
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{v: new(big.Int).Neg(d.int())}
+}
+
+// Mul returns d * o, rounded to Scale places
+func (d Decimal) Mul(o Decimal) Decimal {
+	v := new(big.Int).Mul(d.int(), o.int())
+	return Decimal{v: quoRound(v, scaleFactor)}
+}
+
+// Div returns d / o, rounded to Scale places. It panics if o is zero.
+func (d Decimal) Div(o Decimal) Decimal {
+	if o.IsZero() {
+		panic("money: division by zero")
+	}
+	v := new(big.Int).Mul(d.int(), scaleFactor)
+	return Decimal{v: quoRound(v, o.int())}
+}
+
+// quoRound returns n / m rounded half away from zero
+func quoRound(n, m *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, m, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(new(big.Int).Abs(m)) >= 0 {
+		if n.Sign()*m.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Cmp compares d and o and returns -1, 0 or +1
+func (d Decimal) Cmp(o Decimal) int {
+	return d.int().Cmp(o.int())
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero reports whether d is 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Round rounds d to places fractional digits, halves away from zero
+func (d Decimal) Round(places int) Decimal {
+	if places >= Scale {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Scale-places)), nil)
+	half := new(big.Int).Quo(unit, big.NewInt(2))
+
+	abs := new(big.Int).Abs(d.int())
+	q, r := new(big.Int).QuoRem(abs, unit, new(big.Int))
+	if r.Cmp(half) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	q.Mul(q, unit)
+	if d.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Decimal{v: q}
+}
+
+// RoundUp rounds d to places fractional digits, away from zero.
+// Invoice amounts use it so conversion never leaves us short.
+func (d Decimal) RoundUp(places int) Decimal {
+	if places >= Scale {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Scale-places)), nil)
+	abs := new(big.Int).Abs(d.int())
+	q, r := new(big.Int).QuoRem(abs, unit, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	q.Mul(q, unit)
+	if d.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Decimal{v: q}
+}
+
+// StringFixed formats d rounded to exactly places fractional digits
+func (d Decimal) StringFixed(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	if places > Scale {
+		places = Scale
+	}
+
+	s := d.Round(places).format()
+	whole, frac, _ := strings.Cut(s, ".")
+	if places == 0 {
+		return whole
+	}
+	return whole + "." + frac + strings.Repeat("0", places-len(frac))
+}
+
+// String formats d without trailing zeros, e.g. "0.5" or "12"
+func (d Decimal) String() string {
+	return d.format()
+}
+
+func (d Decimal) format() string {
+	abs := new(big.Int).Abs(d.int())
+	q, r := new(big.Int).QuoRem(abs, scaleFactor, new(big.Int))
+
+	s := q.String()
+	if r.Sign() != 0 {
+		frac := r.String()
+		frac = strings.Repeat("0", Scale-len(frac)) + frac
+		s += "." + strings.TrimRight(frac, "0")
+	}
+	if d.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalText encodes d as a plain decimal string
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses a plain decimal string
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// UnmarshalJSON accepts both JSON strings and bare JSON numbers, so amounts
+// from APIs that send numbers are still parsed exactly from their digits.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	if len(data) == 0 {
+		*d = Decimal{}
+		return nil
+	}
+	return d.UnmarshalText(data)
+}
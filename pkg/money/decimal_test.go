@@ -0,0 +1,149 @@
+package money
+
+import "testing"
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "12", want: "12"},
+		{in: "-0.5", want: "-0.5"},
+		{in: "+1.25", want: "1.25"},
+		{in: " 3.10 ", want: "3.1"},
+		{in: ".5", want: "0.5"},
+		{in: "5.", want: "5"},
+		{in: "0.000001", want: "0.000001"},
+		{in: "0.000000000000000001", want: "0.000000000000000001"},
+		{in: "123456789012345678901234567890", want: "123456789012345678901234567890"},
+		{in: "-0", want: "0"},
+		{in: "", wantErr: true},
+		{in: "   ", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "1e5", wantErr: true},
+		{in: "1,000", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "0x10", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "0.0000000000000000001", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	d := MustParseDecimal
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add", d("0.1").Add(d("0.2")), "0.3"},
+		{"add negative", d("1").Add(d("-1.5")), "-0.5"},
+		{"add zero value", Zero.Add(d("2")), "2"},
+		{"sub", d("10").Sub(d("0.01")), "9.99"},
+		{"sub below zero", d("0.5").Sub(d("0.75")), "-0.25"},
+		{"neg", d("1.5").Neg(), "-1.5"},
+		{"mul", d("1.5").Mul(d("2.5")), "3.75"},
+		{"mul rounds at scale", d("0.000000000000000001").Mul(d("0.5")), "0.000000000000000001"},
+		{"div", d("1").Div(d("4")), "0.25"},
+		{"div rounds at scale", d("2").Div(d("3")), "0.666666666666666667"},
+		{"div negative rounds away from zero", d("-2").Div(d("3")), "-0.666666666666666667"},
+		{"round half up", d("0.125").Round(2), "0.13"},
+		{"round down", d("0.124").Round(2), "0.12"},
+		{"round negative half", d("-0.125").Round(2), "-0.13"},
+		{"round to whole", d("2.5").Round(0), "3"},
+		{"round up", d("0.121").RoundUp(2), "0.13"},
+		{"round up exact", d("0.12").RoundUp(2), "0.12"},
+		{"round up negative", d("-0.121").RoundUp(2), "-0.13"},
+		{"from int", NewFromInt(-7), "-7"},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDecimalCompare(t *testing.T) {
+	d := MustParseDecimal
+	tests := []struct {
+		a, b Decimal
+		cmp  int
+	}{
+		{d("1"), d("1.0"), 0},
+		{d("0.1"), d("0.10000000000000001"), -1},
+		{d("-1"), d("-2"), 1},
+		{Zero, d("0"), 0},
+		{Zero, d("-0.000000000000000001"), 1},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Cmp(tt.b); got != tt.cmp {
+			t.Errorf("%s.Cmp(%s) = %d, want %d", tt.a, tt.b, got, tt.cmp)
+		}
+	}
+	if !Zero.IsZero() || Zero.Sign() != 0 {
+		t.Errorf("zero value is not zero")
+	}
+}
+
+func TestDecimalStringFixed(t *testing.T) {
+	d := MustParseDecimal
+	tests := []struct {
+		in     Decimal
+		places int
+		want   string
+	}{
+		{d("0.5"), 2, "0.50"},
+		{d("12"), 2, "12.00"},
+		{d("1.005"), 2, "1.01"},
+		{d("-3.14159"), 3, "-3.142"},
+		{d("7.6"), 0, "8"},
+		{Zero, 2, "0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.StringFixed(tt.places); got != tt.want {
+			t.Errorf("%s.StringFixed(%d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"0.1"`, "0.1"},
+		{`0.1`, "0.1"},
+		{`12345678.123456789012345678`, "12345678.123456789012345678"},
+		{`null`, "0"},
+		{`""`, "0"},
+	}
+	for _, tt := range tests {
+		var got Decimal
+		if err := got.UnmarshalJSON([]byte(tt.in)); err != nil {
+			t.Errorf("UnmarshalJSON(%s) failed: %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// USD is the unit all agent budgets and service prices are expressed in
+const USD = "USD"
+
+// stablecoins are valued 1:1 against USD
+var stablecoins = map[string]bool{
+	"USDT": true,
+	"USDC": true,
+}
+
+// networks maps currency symbols to the chain SHKeeper pays them out on
+var networks = map[string]string{
+	"USDT": "tron",
+	"USDC": "polygon",
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"TRX":  "tron",
+	"BNB":  "bsc",
+}
+
+// networkSuffixes maps token suffixes like "USDT-TRC20" to their chain
+var networkSuffixes = map[string]string{
+	"TRC20":    "tron",
+	"POLYGON":  "polygon",
+	"ERC20":    "ethereum",
+	"BEP20":    "bsc",
+	"ARBITRUM": "arbitrum",
+	"OPTIMISM": "optimism",
+	"BASE":     "base",
+}
+
+// Network returns the chain a currency is paid out on, or "" if unknown
+func Network(currency string) string {
+	symbol, suffix, ok := strings.Cut(strings.ToUpper(currency), "-")
+	if ok {
+		return networkSuffixes[suffix]
+	}
+	return networks[symbol]
+}
+
+// Money is an exact amount in a given currency
+type Money struct {
+	Amount   Decimal `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// New returns amount in currency
+func New(amount Decimal, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Parse parses a decimal amount in currency
+func Parse(amount, currency string) (Money, error) {
+	d, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(d, currency), nil
+}
+
+// MustParse is like Parse but panics on error.
+// Use it only for constants.
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Dollars returns a USD amount, e.g. Dollars("0.50")
+func Dollars(amount string) Money {
+	return MustParse(amount, USD)
+}
+
+// Add returns m + o. It panics if the currencies differ.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount.Add(o.Amount), Currency: m.currency(o)}
+}
+
+// Sub returns m - o. It panics if the currencies differ.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount.Sub(o.Amount), Currency: m.currency(o)}
+}
+
+// Cmp compares m and o and returns -1, 0 or +1. It panics if the currencies differ.
+func (m Money) Cmp(o Money) int {
+	m.mustMatch(o)
+	return m.Amount.Cmp(o.Amount)
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount
+func (m Money) Sign() int {
+	return m.Amount.Sign()
+}
+
+// IsZero reports whether the amount is 0
+func (m Money) IsZero() bool {
+	return m.Amount.IsZero()
+}
+
+// SameCurrency reports whether m and o can be added or compared
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == o.Currency || m.IsZero() && m.Currency == "" || o.IsZero() && o.Currency == ""
+}
+
+// USDValue returns the USD value of m if it is USD or a USD stablecoin
+func (m Money) USDValue() (Money, bool) {
+	if m.Currency == USD || IsStablecoin(m.Currency) {
+		return New(m.Amount, USD), true
+	}
+	return Money{}, false
+}
+
+// String formats USD as "$0.50" and everything else as "0.5 USDT"
+func (m Money) String() string {
+	if m.Currency == USD {
+		if m.Sign() < 0 {
+			return "-$" + m.Amount.Neg().StringFixed(2)
+		}
+		return "$" + m.Amount.StringFixed(2)
+	}
+	return fmt.Sprintf("%s %s", m.Amount, m.Currency)
+}
+
+// IsStablecoin reports whether currency is pegged 1:1 to USD.
+// Network suffixes such as "USDT-TRC20" are ignored.
+func IsStablecoin(currency string) bool {
+	symbol, _, _ := strings.Cut(strings.ToUpper(currency), "-")
+	return stablecoins[symbol]
+}
+
+// currency picks the currency of the result; an untyped zero adopts the other side's
+func (m Money) currency(o Money) string {
+	if m.Currency == "" {
+		return o.Currency
+	}
+	return m.Currency
+}
+
+func (m Money) mustMatch(o Money) {
+	if !m.SameCurrency(o) {
+		panic(fmt.Sprintf("money: currency mismatch %s vs %s", m.Currency, o.Currency))
+	}
+}
@@ -0,0 +1,84 @@
+package money
+
+import "testing"
+
+func TestMoneyArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want string
+	}{
+		{"add", Dollars("0.50").Add(Dollars("0.25")), "$0.75"},
+		{"sub", Dollars("1").Sub(Dollars("1.25")), "-$0.25"},
+		{"add untyped zero", Money{}.Add(MustParse("1.5", "usdt")), "1.5 USDT"},
+		{"sub untyped zero", MustParse("2", "BTC").Sub(Money{}), "2 BTC"},
+		{"crypto", MustParse("0.001", "BTC").Add(MustParse("0.0005", "btc")), "0.0015 BTC"},
+	}
+	for _, tt := range tests {
+		if got := tt.got.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyCurrencyMismatchPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func()
+	}{
+		{"add", func() { Dollars("1").Add(MustParse("1", "USDT")) }},
+		{"sub", func() { MustParse("1", "BTC").Sub(MustParse("1", "ETH")) }},
+		{"cmp", func() { Dollars("1").Cmp(MustParse("1", "USDT")) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s across currencies did not panic", tt.name)
+				}
+			}()
+			tt.op()
+		}()
+	}
+}
+
+func TestUSDValue(t *testing.T) {
+	tests := []struct {
+		in     Money
+		want   string
+		wantOK bool
+	}{
+		{Dollars("2"), "$2.00", true},
+		{MustParse("3.5", "USDT"), "$3.50", true},
+		{MustParse("3.5", "USDT-TRC20"), "$3.50", true},
+		{MustParse("1", "usdc-polygon"), "$1.00", true},
+		{MustParse("1", "BTC"), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.in.USDValue()
+		if ok != tt.wantOK || ok && got.String() != tt.want {
+			t.Errorf("%s.USDValue() = %s, %v, want %s, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     string
+	}{
+		{"USDT", "tron"},
+		{"usdt-trc20", "tron"},
+		{"USDT-ERC20", "ethereum"},
+		{"USDC-POLYGON", "polygon"},
+		{"BTC", "bitcoin"},
+		{"BNB", "bsc"},
+		{"USDC-SOLANA", ""},
+		{"DOGE", ""},
+	}
+	for _, tt := range tests {
+		if got := Network(tt.currency); got != tt.want {
+			t.Errorf("Network(%q) = %q, want %q", tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package orders
+
+import (
+	"time"
+
+	"clawclack/pkg/money"
+	"clawclack/pkg/rates"
+)
+
+// State is the lifecycle state of an order
+type State string
+
+const (
+	StatePending   State = "pending"   // invoice created, waiting for payment
+	StatePaid      State = "paid"      // payment confirmed by SHKeeper
+	StateUnderpaid State = "underpaid" // less than the price arrived, waiting for a top-up
+	StateFulfilled State = "fulfilled" // service delivered
+	StateExpired   State = "expired"   // invoice expired without payment
+	StateFailed    State = "failed"    // invoice or fulfillment failed
+	StateCancelled State = "cancelled" // cancelled before payment
+)
+
+// Open reports whether the order still needs to be watched for payment
+func (s State) Open() bool {
+	return s == StatePending
+}
+
+// Settleable reports whether a payment arriving now should still be booked.
+// Expired and cancelled orders accept late payments so no funds are dropped.
+func (s State) Settleable() bool {
+	return s == StatePending || s == StateExpired || s == StateCancelled
+}
+
+// Order ties a SHKeeper invoice to the Matrix request that created it
+type Order struct {
+	ID          string        `json:"id"`
+	Sender      string        `json:"sender"`
+	RoomID      string        `json:"room_id"`
+	Service     string        `json:"service"`
+	Args        []string      `json:"args,omitempty"`
+	Price       money.Decimal `json:"price"`
+	Currency    string        `json:"currency"`
+	PriceUSD    money.Decimal `json:"price_usd"`              // USD price the invoice was converted from
+	Quote       *rates.Quote  `json:"quote,omitempty"`        // rate locked in when the invoice was created
+	Received    money.Decimal `json:"received"`               // what actually arrived on-chain
+	Excess      money.Decimal `json:"excess"`                 // overpayment owed back to the sender
+	Refunded    money.Decimal `json:"refunded"`               // already queued for refund on top of Excess
+	ParentID    string        `json:"parent_id,omitempty"`    // set on top-up orders for an underpaid order
+	TopUpID     string        `json:"top_up_id,omitempty"`    // latest top-up order for the missing amount
+	PaidCredits bool          `json:"paid_credits,omitempty"` // paid from the sender's prepaid credits
+	Late        bool          `json:"late,omitempty"`         // paid after the invoice expired
+	State       State         `json:"state"`
+	PaymentURL  string        `json:"payment_url,omitempty"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	Error       string        `json:"error,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Amount returns the invoiced price with its currency
+func (o *Order) Amount() money.Money {
+	return money.New(o.Price, o.Currency)
+}
+
+// USDValue converts an amount paid on this order to USD at the locked rate
+func (o *Order) USDValue(amount money.Money) (money.Money, bool) {
+	if usd, ok := amount.USDValue(); ok {
+		return usd, true
+	}
+	if o.Quote == nil || amount.Currency != o.Currency {
+		return money.Money{}, false
+	}
+	return money.New(amount.Amount.Mul(o.Quote.Rate).Round(2), money.USD), true
+}
@@ -0,0 +1,31 @@
+package orders
+
+import (
+	"testing"
+
+	"clawclack/pkg/money"
+	"clawclack/pkg/rates"
+)
+
+func TestOrderUSDValue(t *testing.T) {
+	locked := &Order{Currency: "BTC", Quote: &rates.Quote{Currency: "BTC", Rate: money.MustParseDecimal("60000")}}
+	tests := []struct {
+		name   string
+		order  *Order
+		amount money.Money
+		want   string
+		wantOK bool
+	}{
+		{"at the locked rate", locked, money.MustParse("0.0001", "BTC"), "$6.00", true},
+		{"rounded to cents", locked, money.MustParse("0.00001234", "BTC"), "$0.74", true},
+		{"stablecoins need no rate", locked, money.MustParse("2.5", "USDT-TRC20"), "$2.50", true},
+		{"other coins have no rate", locked, money.MustParse("1", "ETH"), "", false},
+		{"no locked rate", &Order{Currency: "BTC"}, money.MustParse("1", "BTC"), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.order.USDValue(tt.amount)
+		if ok != tt.wantOK || ok && got.String() != tt.want {
+			t.Errorf("%s: USDValue(%s) = %s, %v, want %s, %v", tt.name, tt.amount, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
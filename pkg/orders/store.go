@@ -0,0 +1,199 @@
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ordersBucket     = []byte("orders")
+	currenciesBucket = []byte("currencies")
+)
+
+// ErrNotFound is returned when an order ID is unknown
+var ErrNotFound = errors.New("order not found")
+
+// Store persists orders in an embedded bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the order database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open order store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{ordersBucket, currenciesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new order. The order ID must be unique.
+func (s *Store) Create(order *Order) error {
+	now := time.Now()
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+	if order.State == "" {
+		order.State = StatePending
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+		if b.Get([]byte(order.ID)) != nil {
+			return fmt.Errorf("order %s already exists", order.ID)
+		}
+		return put(b, order)
+	})
+}
+
+// Get loads an order by ID
+func (s *Store) Get(orderID string) (*Order, error) {
+	var order *Order
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		order, err = get(tx.Bucket(ordersBucket), orderID)
+		return err
+	})
+	return order, err
+}
+
+// Update applies fn to the stored order inside a single transaction.
+// Returning an error from fn aborts the update.
+func (s *Store) Update(orderID string, fn func(order *Order) error) (*Order, error) {
+	var order *Order
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+
+		var err error
+		order, err = get(b, orderID)
+		if err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+		order.UpdatedAt = time.Now()
+		return put(b, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Transition moves an order from one of the given states to next.
+// It reports false if the order was not in any of the expected states,
+// which lets concurrent watchers settle an order exactly once.
+func (s *Store) Transition(orderID string, next State, from ...State) (*Order, bool, error) {
+	moved := false
+	order, err := s.Update(orderID, func(order *Order) error {
+		for _, state := range from {
+			if order.State == state {
+				order.State = next
+				moved = true
+				return nil
+			}
+		}
+		return errNoTransition
+	})
+	if errors.Is(err, errNoTransition) {
+		order, err = s.Get(orderID)
+		return order, false, err
+	}
+	return order, moved, err
+}
+
+// ListOpen returns all orders that are still waiting for payment
+func (s *Store) ListOpen() ([]*Order, error) {
+	return s.List(func(order *Order) bool {
+		return order.State.Open()
+	})
+}
+
+// List returns all orders matching filter (or every order if filter is nil)
+func (s *Store) List(filter func(order *Order) bool) ([]*Order, error) {
+	var result []*Order
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ordersBucket).ForEach(func(k, v []byte) error {
+			var order Order
+			if err := json.Unmarshal(v, &order); err != nil {
+				return fmt.Errorf("failed to decode order %s: %w", k, err)
+			}
+			if filter == nil || filter(&order) {
+				result = append(result, &order)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+var errNoTransition = errors.New("no transition")
+
+// SetCurrency remembers which currency a user wants service invoices in
+func (s *Store) SetCurrency(userID, currency string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(currenciesBucket).Put([]byte(userID), []byte(currency))
+	})
+}
+
+// Currency returns the user's preferred invoice currency, or "" if none is set
+func (s *Store) Currency(userID string) (string, error) {
+	var currency string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		currency = string(tx.Bucket(currenciesBucket).Get([]byte(userID)))
+		return nil
+	})
+	return currency, err
+}
+
+func get(b *bolt.Bucket, orderID string) (*Order, error) {
+	data := b.Get([]byte(orderID))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var order Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("failed to decode order %s: %w", orderID, err)
+	}
+	return &order, nil
+}
+
+func put(b *bolt.Bucket, order *Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(order.ID), data)
+}
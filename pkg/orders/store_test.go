@@ -0,0 +1,191 @@
+package orders
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "data", "orders.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreCreateAndGet(t *testing.T) {
+	store := openTestStore(t)
+
+	if err := store.Create(&Order{ID: "o1", Sender: "@alice:example.org", Service: "!code"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Create(&Order{ID: "o1"}); err == nil {
+		t.Error("Create with a duplicate ID succeeded")
+	}
+
+	order, err := store.Get("o1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if order.State != StatePending || order.Sender != "@alice:example.org" || order.CreatedAt.IsZero() {
+		t.Errorf("Get = %+v, want a pending order from alice with a creation time", order)
+	}
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unknown order = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	store := openTestStore(t)
+	if err := store.Create(&Order{ID: "o1"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := store.Update("o1", func(o *Order) error {
+		o.Error = "half written"
+		return errors.New("abort")
+	}); err == nil {
+		t.Error("Update did not return the callback error")
+	}
+	if order, _ := store.Get("o1"); order.Error != "" {
+		t.Errorf("aborted update was stored: %q", order.Error)
+	}
+
+	order, err := store.Update("o1", func(o *Order) error {
+		o.PaymentURL = "https://pay.example.org/o1"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if stored, _ := store.Get("o1"); stored.PaymentURL != order.PaymentURL {
+		t.Errorf("stored payment URL = %q, want %q", stored.PaymentURL, order.PaymentURL)
+	}
+
+	if _, err := store.Update("missing", func(*Order) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update unknown order = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreTransition(t *testing.T) {
+	tests := []struct {
+		name  string
+		state State
+		next  State
+		from  []State
+		moved bool
+		want  State
+	}{
+		{"from the expected state", StatePending, StatePaid, []State{StatePending}, true, StatePaid},
+		{"from one of several states", StateExpired, StatePaid, []State{StatePending, StateExpired}, true, StatePaid},
+		{"already moved on", StatePaid, StatePaid, []State{StatePending}, false, StatePaid},
+		{"no expected states", StatePending, StateFailed, nil, false, StatePending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openTestStore(t)
+			if err := store.Create(&Order{ID: "o1", State: tt.state}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			order, moved, err := store.Transition("o1", tt.next, tt.from...)
+			if err != nil {
+				t.Fatalf("Transition failed: %v", err)
+			}
+			if moved != tt.moved || order.State != tt.want {
+				t.Errorf("Transition = %s, %v, want %s, %v", order.State, moved, tt.want, tt.moved)
+			}
+		})
+	}
+}
+
+func TestStoreTransitionOnce(t *testing.T) {
+	store := openTestStore(t)
+	if err := store.Create(&Order{ID: "o1"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Concurrent watchers settle an order exactly once
+	results := make(chan bool, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			_, moved, err := store.Transition("o1", StatePaid, StatePending)
+			if err != nil {
+				t.Errorf("Transition failed: %v", err)
+			}
+			results <- moved
+		}()
+	}
+	settled := 0
+	for i := 0; i < cap(results); i++ {
+		if <-results {
+			settled++
+		}
+	}
+	if settled != 1 {
+		t.Errorf("order settled %d times, want once", settled)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	store := openTestStore(t)
+	for id, state := range map[string]State{
+		"pending":   StatePending,
+		"paid":      StatePaid,
+		"expired":   StateExpired,
+		"cancelled": StateCancelled,
+		"fulfilled": StateFulfilled,
+	} {
+		if err := store.Create(&Order{ID: id, State: state}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	open, err := store.ListOpen()
+	if err != nil {
+		t.Fatalf("ListOpen failed: %v", err)
+	}
+	if len(open) != 1 || open[0].ID != "pending" {
+		t.Errorf("ListOpen = %v, want only the pending order", ids(open))
+	}
+
+	all, err := store.List(nil)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(all) != 5 {
+		t.Errorf("List(nil) = %v, want every order", ids(all))
+	}
+
+	done, _ := store.List(func(o *Order) bool { return o.State == StatePaid || o.State == StateFulfilled })
+	if got := ids(done); len(got) != 2 || got[0] != "fulfilled" || got[1] != "paid" {
+		t.Errorf("filtered orders = %v, want fulfilled and paid", got)
+	}
+}
+
+func TestStoreCurrency(t *testing.T) {
+	store := openTestStore(t)
+
+	if currency, err := store.Currency("@alice:example.org"); err != nil || currency != "" {
+		t.Errorf("Currency before SetCurrency = %q, %v, want empty", currency, err)
+	}
+	if err := store.SetCurrency("@alice:example.org", "BTC"); err != nil {
+		t.Fatalf("SetCurrency failed: %v", err)
+	}
+	if currency, _ := store.Currency("@alice:example.org"); currency != "BTC" {
+		t.Errorf("Currency = %q, want BTC", currency)
+	}
+}
+
+// ids lists order IDs in key order
+func ids(orders []*Order) []string {
+	var result []string
+	for _, o := range orders {
+		result = append(result, o.ID)
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+package payees
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// evmNetworks share Ethereum's address format
+var evmNetworks = map[string]bool{
+	"ethereum": true,
+	"polygon":  true,
+	"bsc":      true,
+	"arbitrum": true,
+	"optimism": true,
+	"base":     true,
+}
+
+// Networks returns the networks payees can be added on
+func Networks() []string {
+	return []string{"tron", "bitcoin", "ethereum", "polygon", "bsc", "arbitrum", "optimism", "base"}
+}
+
+// IsEVM reports whether network uses Ethereum-style addresses
+func IsEVM(network string) bool {
+	return evmNetworks[network]
+}
+
+// NormalizeAddress validates address for network and returns its canonical
+// form: TRON and legacy Bitcoin addresses as given, SegWit addresses in
+// lower case and EVM addresses with their EIP-55 checksum.
+func NormalizeAddress(network, address string) (string, error) {
+	switch {
+	case network == "tron":
+		if err := validateTron(address); err != nil {
+			return "", err
+		}
+		return address, nil
+	case network == "bitcoin":
+		return normalizeBitcoin(address)
+	case evmNetworks[network]:
+		return checksumEVM(address)
+	}
+	return "", fmt.Errorf("unsupported network %q, use one of %s", network, strings.Join(Networks(), ", "))
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// validateTron checks a base58check TRON address: 0x41, a 20-byte account
+// and the first 4 bytes of its double SHA-256
+func validateTron(address string) error {
+	data, err := decodeBase58(address)
+	if err != nil {
+		return err
+	}
+	if len(data) != 25 || data[0] != 0x41 {
+		return errors.New("not a TRON address, they start with T and are 34 characters long")
+	}
+
+	if !base58Checksum(data) {
+		return errors.New("TRON address checksum does not match, check for typos")
+	}
+	return nil
+}
+
+// base58Checksum reports whether the last 4 bytes of data are the start of
+// the double SHA-256 of the rest
+func base58Checksum(data []byte) bool {
+	first := sha256.Sum256(data[:len(data)-4])
+	second := sha256.Sum256(first[:])
+	return bytes.Equal(second[:4], data[len(data)-4:])
+}
+
+// normalizeBitcoin checks a mainnet Bitcoin address: base58check P2PKH or
+// P2SH, or a bech32 (SegWit v0) or bech32m (Taproot and later) bc1 address
+func normalizeBitcoin(address string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(address), "bc1") {
+		return normalizeSegwit(address)
+	}
+
+	data, err := decodeBase58(address)
+	if err != nil {
+		return "", err
+	}
+	if len(data) != 25 || data[0] != 0x00 && data[0] != 0x05 {
+		return "", errors.New("not a Bitcoin address, they start with 1, 3 or bc1")
+	}
+	if !base58Checksum(data) {
+		return "", errors.New("Bitcoin address checksum does not match, check for typos")
+	}
+	return address, nil
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of BIP 173 and BIP 350
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// normalizeSegwit decodes a bc1 address and checks its checksum, witness
+// version and program length
+func normalizeSegwit(address string) (string, error) {
+	lower := strings.ToLower(address)
+	if address != lower && address != strings.ToUpper(address) {
+		return "", errors.New("Bitcoin address mixes upper and lower case")
+	}
+	if len(lower) > 90 {
+		return "", errors.New("not a Bitcoin address, it is too long")
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	hrp, rest := lower[:sep], lower[sep+1:]
+	if hrp != "bc" || len(rest) < 7 {
+		return "", errors.New("not a Bitcoin address, they start with 1, 3 or bc1")
+	}
+
+	values := make([]byte, 0, len(hrp)*2+1+len(rest))
+	for _, c := range []byte(hrp) {
+		values = append(values, c>>5)
+	}
+	values = append(values, 0)
+	for _, c := range []byte(hrp) {
+		values = append(values, c&31)
+	}
+	data := make([]byte, len(rest))
+	for i := range rest {
+		v := strings.IndexByte(bech32Charset, rest[i])
+		if v < 0 {
+			return "", fmt.Errorf("invalid bech32 character %q", rest[i])
+		}
+		data[i] = byte(v)
+	}
+	values = append(values, data...)
+
+	version := data[0]
+	program, ok := convertBits(data[1:len(data)-6], 5, 8)
+	checksum := bech32Polymod(values)
+	switch {
+	case !ok:
+		return "", errors.New("Bitcoin address has invalid padding")
+	case version == 0 && checksum != bech32Const, version > 0 && checksum != bech32mConst:
+		return "", errors.New("Bitcoin address checksum does not match, check for typos")
+	case version > 16:
+		return "", fmt.Errorf("unknown SegWit version %d", version)
+	case version == 0 && len(program) != 20 && len(program) != 32,
+		len(program) < 2 || len(program) > 40:
+		return "", errors.New("Bitcoin address has an invalid witness program length")
+	}
+	return lower, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// convertBits regroups data from groups of from bits into groups of to
+// bits, rejecting leftover padding that is not all zero
+func convertBits(data []byte, from, to uint) ([]byte, bool) {
+	var acc, bits uint
+	var out []byte
+	for _, v := range data {
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&(1<<to-1)))
+		}
+	}
+	if bits >= from || acc<<(to-bits)&(1<<to-1) != 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	// Leading '1's encode leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// checksumEVM validates a hex address and returns its EIP-55 form. All-lower
+// and all-upper addresses carry no checksum and are accepted as they are;
+// mixed case must match the checksum exactly.
+func checksumEVM(address string) (string, error) {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return "", errors.New("not an EVM address, they are 0x followed by 40 hex digits")
+	}
+	digits := address[2:]
+	raw, err := hex.DecodeString(digits)
+	if err != nil {
+		return "", errors.New("not an EVM address, they are 0x followed by 40 hex digits")
+	}
+	if bytes.Equal(raw, make([]byte, 20)) {
+		return "", errors.New("refusing the zero address")
+	}
+
+	lower := strings.ToLower(digits)
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	sum := hash.Sum(nil)
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		// A letter is uppercased when the matching hash nibble is 8 or more
+		nibble := sum[i/2] >> 4
+		if i%2 == 1 {
+			nibble = sum[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	result := "0x" + string(checksummed)
+	if digits != lower && digits != strings.ToUpper(digits) && address != result {
+		return "", errors.New("EVM address checksum does not match, check for typos")
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package payees
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		address string
+		want    string // "" when the address is rejected
+	}{
+		{"tron", "tron", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"},
+		{"tron typo", "tron", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u", ""},
+		{"tron bitcoin address", "tron", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", ""},
+		{"tron not base58", "tron", "T0000000000000000000000000000000l", ""},
+		{"bitcoin P2PKH", "bitcoin", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"bitcoin P2SH", "bitcoin", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+		{"bitcoin P2PKH typo", "bitcoin", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", ""},
+		{"segwit lower cased", "bitcoin", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"segwit mixed case", "bitcoin", "bc1qW508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", ""},
+		{"segwit typo", "bitcoin", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", ""},
+		{"taproot", "bitcoin", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"taproot with a bech32 checksum", "bitcoin", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", ""},
+		{"testnet", "bitcoin", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", ""},
+		{"evm checksummed", "ethereum", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{"evm lower case gets a checksum", "polygon", "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+		{"evm upper case gets a checksum", "base", "0xDBF03B407C01E7CD3CBEA99509D93F8DDDC8C6FB", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"},
+		{"evm bad checksum", "ethereum", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", ""},
+		{"evm too short", "bsc", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", ""},
+		{"evm zero address", "ethereum", "0x0000000000000000000000000000000000000000", ""},
+		{"unsupported network", "solana", "4Nd1mBQtrMJVYVfKf2PJy9NZUZdTAsp7D4xWLs4gDB4T", ""},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeAddress(tt.network, tt.address)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: NormalizeAddress accepted %s as %s", tt.name, tt.address, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: NormalizeAddress failed: %v", tt.name, err)
+		case got != tt.want:
+			t.Errorf("%s: NormalizeAddress = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package payees
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var payeesBucket = []byte("payees")
+
+var (
+	// ErrNotFound is returned when no payee has the name
+	ErrNotFound = errors.New("payee not found")
+	// ErrExists is returned when a name or address is already in the book
+	ErrExists = errors.New("payee already exists")
+)
+
+// Payee is an admin-approved destination for the agent's payouts
+type Payee struct {
+	Name    string    `json:"name"`
+	Network string    `json:"network"`
+	Address string    `json:"address"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Store is the address book, kept in an embedded bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the address book at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open address book: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(payeesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add validates the payee's address and adds it to the book
+func (s *Store) Add(p *Payee) error {
+	p.Name = strings.ToLower(p.Name)
+	p.Network = strings.ToLower(p.Network)
+	address, err := NormalizeAddress(p.Network, p.Address)
+	if err != nil {
+		return err
+	}
+	p.Address = address
+	if p.AddedAt.IsZero() {
+		p.AddedAt = time.Now()
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(payeesBucket)
+		if b.Get([]byte(p.Name)) != nil {
+			return fmt.Errorf("%w: %s", ErrExists, p.Name)
+		}
+		if existing, ok := find(b, p.Address); ok {
+			return fmt.Errorf("%w: %s is already saved as %s", ErrExists, p.Address, existing.Name)
+		}
+		return b.Put([]byte(p.Name), data)
+	})
+}
+
+// Remove deletes the payee with name
+func (s *Store) Remove(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(payeesBucket)
+		key := []byte(strings.ToLower(name))
+		if b.Get(key) == nil {
+			return ErrNotFound
+		}
+		return b.Delete(key)
+	})
+}
+
+// Get returns the payee with name
+func (s *Store) Get(name string) (*Payee, error) {
+	var p Payee
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(payeesBucket).Get([]byte(strings.ToLower(name)))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List returns every payee sorted by name
+func (s *Store) List() ([]*Payee, error) {
+	var result []*Payee
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(payeesBucket).ForEach(func(_, v []byte) error {
+			var p Payee
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			result = append(result, &p)
+			return nil
+		})
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, err
+}
+
+// Approved reports whether address belongs to a payee in the book on
+// network. EVM addresses match regardless of case.
+func (s *Store) Approved(address, network string) bool {
+	var payee *Payee
+	err := s.db.View(func(tx *bolt.Tx) error {
+		payee, _ = find(tx.Bucket(payeesBucket), address)
+		return nil
+	})
+	return err == nil && payee != nil && payee.Network == strings.ToLower(network)
+}
+
+// find looks a payee up by address
+func find(b *bolt.Bucket, address string) (*Payee, bool) {
+	var match *Payee
+	_ = b.ForEach(func(_, v []byte) error {
+		var p Payee
+		if json.Unmarshal(v, &p) != nil {
+			return nil
+		}
+		if p.Address == address || IsEVM(p.Network) && strings.EqualFold(p.Address, address) {
+			match = &p
+		}
+		return nil
+	})
+	return match, match != nil
+}
@@ -0,0 +1,118 @@
+package payees
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	tronAddress = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	evmAddress  = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "data", "payees.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreAdd(t *testing.T) {
+	store := openTestStore(t)
+
+	if err := store.Add(&Payee{Name: "Hosting", Network: "TRON", Address: tronAddress, AddedBy: "@admin:example.org"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Add(&Payee{Name: "tools", Network: "ethereum", Address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		payee *Payee
+		want  error
+	}{
+		{"same name", &Payee{Name: "HOSTING", Network: "bitcoin", Address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"}, ErrExists},
+		{"same address", &Payee{Name: "vps", Network: "tron", Address: tronAddress}, ErrExists},
+		{"same EVM address in upper case", &Payee{Name: "other", Network: "ethereum", Address: "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"}, ErrExists},
+		{"invalid address", &Payee{Name: "typo", Network: "tron", Address: tronAddress[:33] + "u"}, nil},
+	}
+	for _, tt := range tests {
+		err := store.Add(tt.payee)
+		if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: Add = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	payee, err := store.Get("TOOLS")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if payee.Name != "tools" || payee.Address != evmAddress || payee.AddedAt.IsZero() {
+		t.Errorf("Get = %+v, want the checksummed address under a lower case name", payee)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "hosting" || list[1].Name != "tools" {
+		t.Errorf("List returned %d payees, want hosting and tools in order", len(list))
+	}
+}
+
+func TestStoreRemove(t *testing.T) {
+	store := openTestStore(t)
+	if err := store.Add(&Payee{Name: "hosting", Network: "tron", Address: tronAddress}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if err := store.Remove("Hosting"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := store.Get("hosting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove = %v, want ErrNotFound", err)
+	}
+	if err := store.Remove("hosting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove = %v, want ErrNotFound", err)
+	}
+	if store.Approved(tronAddress, "tron") {
+		t.Error("removed payee is still approved")
+	}
+}
+
+func TestStoreApproved(t *testing.T) {
+	store := openTestStore(t)
+	for _, p := range []*Payee{
+		{Name: "hosting", Network: "tron", Address: tronAddress},
+		{Name: "tools", Network: "polygon", Address: evmAddress},
+	} {
+		if err := store.Add(p); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		address string
+		network string
+		want    bool
+	}{
+		{tronAddress, "tron", true},
+		{tronAddress, "TRON", true},
+		{tronAddress, "ethereum", false}, // saved for another network
+		{evmAddress, "polygon", true},
+		{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "polygon", true},
+		{evmAddress, "ethereum", false}, // same address, but not approved on mainnet
+		{"TUnknownAddress", "tron", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := store.Approved(tt.address, tt.network); got != tt.want {
+			t.Errorf("Approved(%s, %s) = %v, want %v", tt.address, tt.network, got, tt.want)
+		}
+	}
+}
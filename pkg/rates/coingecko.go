@@ -0,0 +1,102 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+const coinGeckoURL = "https://api.coingecko.com/api/v3"
+
+// coinGeckoIDs maps ticker symbols to CoinGecko coin IDs
+var coinGeckoIDs = map[string]string{
+	"BTC":   "bitcoin",
+	"ETH":   "ethereum",
+	"TRX":   "tron",
+	"LTC":   "litecoin",
+	"DOGE":  "dogecoin",
+	"BNB":   "binancecoin",
+	"MATIC": "matic-network",
+	"POL":   "polygon-ecosystem-token",
+	"XMR":   "monero",
+}
+
+// CoinGecko reads USD rates from the CoinGecko simple price API
+type CoinGecko struct {
+	BaseURL string
+	APIKey  string // optional demo API key
+	client  *http.Client
+}
+
+// NewCoinGecko creates a CoinGecko source; an empty baseURL uses the public API
+func NewCoinGecko(baseURL, apiKey string) *CoinGecko {
+	if baseURL == "" {
+		baseURL = coinGeckoURL
+	}
+	return &CoinGecko{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *CoinGecko) Name() string {
+	return "coingecko"
+}
+
+// USDRate returns the USD price of one symbol
+func (c *CoinGecko) USDRate(ctx context.Context, symbol string) (money.Decimal, error) {
+	coinID, ok := coinGeckoIDs[strings.ToUpper(symbol)]
+	if !ok {
+		return money.Decimal{}, fmt.Errorf("no CoinGecko ID for %s", symbol)
+	}
+
+	query := url.Values{"ids": {coinID}, "vs_currencies": {"usd"}, "precision": {"full"}}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/simple/price?"+query.Encode(), nil)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("x-cg-demo-api-key", c.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return money.Decimal{}, fmt.Errorf("coingecko returned status %d", resp.StatusCode)
+	}
+
+	// Prices are decoded as raw numbers so no precision is lost to float64
+	var prices map[string]map[string]json.Number
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return money.Decimal{}, err
+	}
+	price, ok := prices[coinID]["usd"]
+	if !ok {
+		return money.Decimal{}, fmt.Errorf("coingecko has no USD price for %s", symbol)
+	}
+	return parseRate(price.String())
+}
+
+// parseRate parses a price that may use exponent notation, e.g. "1.2e-05"
+func parseRate(s string) (money.Decimal, error) {
+	if !strings.ContainsAny(s, "eE") {
+		return money.ParseDecimal(s)
+	}
+	f, ok := new(big.Float).SetPrec(256).SetString(s)
+	if !ok {
+		return money.Decimal{}, fmt.Errorf("invalid rate %q", s)
+	}
+	return money.ParseDecimal(f.Text('f', money.Scale))
+}
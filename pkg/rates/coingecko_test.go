@@ -0,0 +1,56 @@
+package rates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCoinGeckoUSDRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/price" || r.URL.Query().Get("vs_currencies") != "usd" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-cg-demo-api-key") != "demo-key" {
+			http.Error(w, "missing key", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("ids") {
+		case "bitcoin":
+			w.Write([]byte(`{"bitcoin":{"usd":64123.456789012345}}`))
+		case "dogecoin":
+			w.Write([]byte(`{"dogecoin":{"usd":1.2e-05}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewCoinGecko(srv.URL+"/", "demo-key")
+	tests := []struct {
+		symbol  string
+		want    string
+		wantErr bool
+	}{
+		{"BTC", "64123.456789012345", false}, // no precision lost to float64
+		{"doge", "0.000012", false},
+		{"LTC", "", true},  // no price in the response
+		{"WOOF", "", true}, // no CoinGecko ID
+	}
+	for _, tt := range tests {
+		got, err := c.USDRate(context.Background(), tt.symbol)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("USDRate(%s) error = %v, want error %v", tt.symbol, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.String() != tt.want {
+			t.Errorf("USDRate(%s) = %s, want %s", tt.symbol, got, tt.want)
+		}
+	}
+
+	if _, err := NewCoinGecko(srv.URL, "").USDRate(context.Background(), "BTC"); err == nil {
+		t.Error("USDRate succeeded on an error status")
+	}
+}
@@ -0,0 +1,163 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// PegSource is the source of stablecoin quotes, which are always 1:1
+const PegSource = "peg"
+
+const (
+	defaultCacheTTL = time.Minute
+	defaultValidity = 15 * time.Minute
+	defaultDecimals = 8
+)
+
+// Source looks up the current USD price of one unit of a coin
+type Source interface {
+	Name() string
+	USDRate(ctx context.Context, symbol string) (money.Decimal, error)
+}
+
+// Quote is a USD exchange rate that is honoured until ValidUntil
+type Quote struct {
+	Currency   string        `json:"currency"`
+	Rate       money.Decimal `json:"rate"` // USD per unit
+	Source     string        `json:"source"`
+	QuotedAt   time.Time     `json:"quoted_at"`
+	ValidUntil time.Time     `json:"valid_until"`
+}
+
+// Pegged reports whether the quote is a fixed stablecoin peg rather than a market rate
+func (q *Quote) Pegged() bool {
+	return q.Source == PegSource
+}
+
+// Valid reports whether the quote may still be used at t
+func (q *Quote) Valid(t time.Time) bool {
+	return t.Before(q.ValidUntil)
+}
+
+// decimals is how many places invoices in each coin are rounded up to
+var decimals = map[string]int{
+	"BTC":  8,
+	"LTC":  8,
+	"DOGE": 8,
+	"ETH":  8,
+	"BNB":  8,
+	"TRX":  6,
+	"USDT": 6,
+	"USDC": 6,
+}
+
+// Converter turns USD prices into crypto amounts. Rates are cached for
+// TTL so a burst of invoices does not hit the source every time, and each
+// quote handed out is valid for Validity from when it was fetched.
+type Converter struct {
+	Source   Source
+	TTL      time.Duration
+	Validity time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*Quote
+}
+
+// NewConverter creates a converter backed by source
+func NewConverter(source Source, ttl, validity time.Duration) *Converter {
+	return &Converter{Source: source, TTL: ttl, Validity: validity}
+}
+
+// Quote returns the USD rate for currency. Stablecoins are always 1:1
+// and network suffixes such as "-TRC20" are ignored.
+func (c *Converter) Quote(ctx context.Context, currency string) (*Quote, error) {
+	currency = strings.ToUpper(currency)
+	symbol, _, _ := strings.Cut(currency, "-")
+	now := time.Now()
+
+	if money.IsStablecoin(currency) || symbol == money.USD {
+		return &Quote{
+			Currency:   currency,
+			Rate:       money.NewFromInt(1),
+			Source:     PegSource,
+			QuotedAt:   now,
+			ValidUntil: now.Add(c.validity()),
+		}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.cache[symbol]; ok && now.Sub(cached.QuotedAt) < c.ttl() {
+		quote := *cached
+		quote.Currency = currency
+		return &quote, nil
+	}
+
+	rate, err := c.Source.USDRate(ctx, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s rate from %s: %w", symbol, c.Source.Name(), err)
+	}
+	if rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%s returned an invalid %s rate %s", c.Source.Name(), symbol, rate)
+	}
+
+	quote := &Quote{
+		Currency:   currency,
+		Rate:       rate,
+		Source:     c.Source.Name(),
+		QuotedAt:   now,
+		ValidUntil: now.Add(c.validity()),
+	}
+	if c.cache == nil {
+		c.cache = make(map[string]*Quote)
+	}
+	c.cache[symbol] = quote
+
+	copied := *quote
+	return &copied, nil
+}
+
+// Convert prices usd in currency, rounded up to the coin's usual precision
+// so the converted invoice never covers less than the USD price
+func (c *Converter) Convert(ctx context.Context, usd money.Money, currency string) (money.Money, *Quote, error) {
+	if usd.Currency != money.USD {
+		return money.Money{}, nil, fmt.Errorf("expected a USD price, got %s", usd)
+	}
+
+	quote, err := c.Quote(ctx, currency)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+
+	amount := usd.Amount.Div(quote.Rate).RoundUp(Decimals(currency))
+	return money.New(amount, currency), quote, nil
+}
+
+// Decimals returns the precision invoices in currency are rounded to
+func Decimals(currency string) int {
+	symbol, _, _ := strings.Cut(strings.ToUpper(currency), "-")
+	if places, ok := decimals[symbol]; ok {
+		return places
+	}
+	return defaultDecimals
+}
+
+func (c *Converter) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return defaultCacheTTL
+	}
+	return c.TTL
+}
+
+func (c *Converter) validity() time.Duration {
+	if c.Validity <= 0 {
+		return defaultValidity
+	}
+	return c.Validity
+}
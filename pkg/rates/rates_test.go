@@ -0,0 +1,141 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// fakeSource answers from a fixed table and counts lookups
+type fakeSource struct {
+	rates map[string]string
+	err   error
+	calls int
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) USDRate(ctx context.Context, symbol string) (money.Decimal, error) {
+	s.calls++
+	if s.err != nil {
+		return money.Decimal{}, s.err
+	}
+	rate, ok := s.rates[symbol]
+	if !ok {
+		return money.Decimal{}, errors.New("no rate")
+	}
+	return money.MustParseDecimal(rate), nil
+}
+
+func TestConvert(t *testing.T) {
+	source := &fakeSource{rates: map[string]string{"BTC": "60000", "ETH": "3000", "TRX": "0.12", "XMR": "150"}}
+	c := NewConverter(source, time.Minute, 15*time.Minute)
+
+	tests := []struct {
+		usd      string
+		currency string
+		want     string
+		pegged   bool
+	}{
+		{"10", "BTC", "0.00016667 BTC", false}, // rounded up, never down
+		{"10", "eth", "0.00333334 ETH", false},
+		{"1", "TRX", "8.333334 TRX", false},
+		{"1.5", "XMR", "0.01 XMR", false},
+		{"10", "USDT-TRC20", "10 USDT-TRC20", true},
+		{"2.50", "USDC", "2.5 USDC", true},
+	}
+	for _, tt := range tests {
+		got, quote, err := c.Convert(context.Background(), money.Dollars(tt.usd), tt.currency)
+		if err != nil {
+			t.Errorf("Convert($%s, %s) failed: %v", tt.usd, tt.currency, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Convert($%s, %s) = %s, want %s", tt.usd, tt.currency, got, tt.want)
+		}
+		if quote.Pegged() != tt.pegged {
+			t.Errorf("Convert($%s, %s) quote from %s, want pegged %v", tt.usd, tt.currency, quote.Source, tt.pegged)
+		}
+		if !quote.Valid(time.Now()) || quote.Valid(quote.QuotedAt.Add(15*time.Minute)) {
+			t.Errorf("quote for %s is valid until %s, want 15 minutes after %s", tt.currency, quote.ValidUntil, quote.QuotedAt)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   *fakeSource
+		usd      money.Money
+		currency string
+		want     string
+	}{
+		{"not a USD price", &fakeSource{}, money.MustParse("1", "USDT"), "BTC", "expected a USD price"},
+		{"source error", &fakeSource{err: errors.New("rate limited")}, money.Dollars("1"), "BTC", "rate limited"},
+		{"zero rate", &fakeSource{rates: map[string]string{"BTC": "0"}}, money.Dollars("1"), "BTC", "invalid BTC rate"},
+		{"negative rate", &fakeSource{rates: map[string]string{"BTC": "-1"}}, money.Dollars("1"), "BTC", "invalid BTC rate"},
+	}
+	for _, tt := range tests {
+		_, _, err := NewConverter(tt.source, 0, 0).Convert(context.Background(), tt.usd, tt.currency)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Convert = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestQuoteCache(t *testing.T) {
+	source := &fakeSource{rates: map[string]string{"BTC": "60000", "USDT": "0.5"}}
+	c := NewConverter(source, time.Minute, 0)
+
+	first, err := c.Quote(context.Background(), "BTC")
+	if err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	// Network suffixes share the coin's cached rate
+	second, err := c.Quote(context.Background(), "btc-lightning")
+	if err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	if source.calls != 1 {
+		t.Errorf("source asked %d times, want once", source.calls)
+	}
+	if second.Currency != "BTC-LIGHTNING" || first.Currency != "BTC" || second.Rate.String() != "60000" {
+		t.Errorf("cached quote = %s at %s, want BTC-LIGHTNING at 60000", second.Currency, second.Rate)
+	}
+
+	// Handed out quotes are copies, so callers cannot change the cache
+	first.Rate = money.NewFromInt(1)
+	if third, _ := c.Quote(context.Background(), "BTC"); third.Rate.String() != "60000" {
+		t.Errorf("cached rate = %s after a caller changed its copy", third.Rate)
+	}
+
+	// Stablecoins never reach the source
+	if quote, _ := c.Quote(context.Background(), "USDT-TRC20"); !quote.Pegged() || quote.Rate.String() != "1" {
+		t.Errorf("USDT quote = %s from %s, want 1 from the peg", quote.Rate, quote.Source)
+	}
+	if source.calls != 1 {
+		t.Errorf("source asked %d times, want once", source.calls)
+	}
+
+	// An expired cache entry is fetched again
+	c.cache["BTC"].QuotedAt = time.Now().Add(-2 * time.Minute)
+	if _, err := c.Quote(context.Background(), "BTC"); err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	if source.calls != 2 {
+		t.Errorf("source asked %d times after the cache expired, want twice", source.calls)
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	tests := map[string]int{"BTC": 8, "usdt-trc20": 6, "TRX": 6, "XMR": defaultDecimals}
+	for currency, want := range tests {
+		if got := Decimals(currency); got != want {
+			t.Errorf("Decimals(%s) = %d, want %d", currency, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package refunds
+
+import (
+	"time"
+
+	"clawclack/pkg/money"
+)
+
+// State is the lifecycle state of a refund
+type State string
+
+const (
+	StateQueued   State = "queued"   // waiting for policy or admin approval
+	StateApproved State = "approved" // approved, waiting to be sent (or for a refund address)
+	StateSending  State = "sending"  // payout submitted to SHKeeper
+	StateSent     State = "sent"     // payout succeeded
+	StateRejected State = "rejected" // rejected by an admin
+	StateFailed   State = "failed"   // payout refused by SHKeeper, can be retried by an admin
+	StateUnknown  State = "unknown"  // payout may have gone out, an admin checks SHKeeper and settles it
+)
+
+// Refund is money owed back to a customer for one order
+type Refund struct {
+	ID         string        `json:"id"`
+	OrderID    string        `json:"order_id"`
+	Service    string        `json:"service,omitempty"` // service of the order, "!topup" for credit purchases
+	Sender     string        `json:"sender"`
+	RoomID     string        `json:"room_id"`
+	Amount     money.Decimal `json:"amount"`
+	Currency   string        `json:"currency"`
+	Network    string        `json:"network"`
+	Address    string        `json:"address,omitempty"`
+	Reason     string        `json:"reason"`
+	ValueUSD   money.Decimal `json:"value_usd"` // at the order's locked rate, zero if unknown
+	State      State         `json:"state"`
+	ApprovedBy string        `json:"approved_by,omitempty"` // admin user ID or "policy"
+	Error      string        `json:"error,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// Money returns the refund amount with its currency
+func (r *Refund) Money() money.Money {
+	return money.New(r.Amount, r.Currency)
+}
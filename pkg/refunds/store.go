@@ -0,0 +1,195 @@
+package refunds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	refundsBucket   = []byte("refunds")
+	addressesBucket = []byte("addresses")
+)
+
+// ErrNotFound is returned when a refund ID is unknown
+var ErrNotFound = errors.New("refund not found")
+
+// Store persists refunds and customer refund addresses in an embedded bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the refund database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open refund store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{refundsBucket, addressesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SetAddress registers where a user wants refunds on network to go
+func (s *Store) SetAddress(userID, network, address string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(addressesBucket).Put(addressKey(userID, network), []byte(address))
+	})
+}
+
+// Address returns the user's refund address on network, or "" if none is registered
+func (s *Store) Address(userID, network string) (string, error) {
+	var address string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		address = string(tx.Bucket(addressesBucket).Get(addressKey(userID, network)))
+		return nil
+	})
+	return address, err
+}
+
+// Addresses returns all refund addresses of a user keyed by network
+func (s *Store) Addresses(userID string) (map[string]string, error) {
+	result := make(map[string]string)
+	prefix := addressKey(userID, "")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(addressesBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			result[strings.TrimPrefix(string(k), string(prefix))] = string(v)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func addressKey(userID, network string) []byte {
+	return []byte(userID + "|" + strings.ToLower(network))
+}
+
+// Create stores a new refund in the queued state
+func (s *Store) Create(refund *Refund) error {
+	now := time.Now()
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+	if refund.State == "" {
+		refund.State = StateQueued
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(refundsBucket)
+		if b.Get([]byte(refund.ID)) != nil {
+			return fmt.Errorf("refund %s already exists", refund.ID)
+		}
+		return put(b, refund)
+	})
+}
+
+// Get loads a refund by ID
+func (s *Store) Get(refundID string) (*Refund, error) {
+	var refund *Refund
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		refund, err = get(tx.Bucket(refundsBucket), refundID)
+		return err
+	})
+	return refund, err
+}
+
+// Transition moves a refund from one of the given states to next and lets
+// fn adjust it in the same transaction. It reports false if the refund was
+// not in any of the expected states, so a payout can only be started once.
+func (s *Store) Transition(refundID string, next State, fn func(refund *Refund), from ...State) (*Refund, bool, error) {
+	var refund *Refund
+	moved := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(refundsBucket)
+
+		var err error
+		refund, err = get(b, refundID)
+		if err != nil {
+			return err
+		}
+		for _, state := range from {
+			if refund.State == state {
+				moved = true
+				break
+			}
+		}
+		if !moved {
+			return nil
+		}
+
+		refund.State = next
+		if fn != nil {
+			fn(refund)
+		}
+		refund.UpdatedAt = time.Now()
+		return put(b, refund)
+	})
+	return refund, moved, err
+}
+
+// List returns all refunds matching filter (or every refund if filter is nil)
+func (s *Store) List(filter func(refund *Refund) bool) ([]*Refund, error) {
+	var result []*Refund
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(refundsBucket).ForEach(func(k, v []byte) error {
+			var refund Refund
+			if err := json.Unmarshal(v, &refund); err != nil {
+				return fmt.Errorf("failed to decode refund %s: %w", k, err)
+			}
+			if filter == nil || filter(&refund) {
+				result = append(result, &refund)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+func get(b *bolt.Bucket, refundID string) (*Refund, error) {
+	data := b.Get([]byte(refundID))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var refund Refund
+	if err := json.Unmarshal(data, &refund); err != nil {
+		return nil, fmt.Errorf("failed to decode refund %s: %w", refundID, err)
+	}
+	return &refund, nil
+}
+
+func put(b *bolt.Bucket, refund *Refund) error {
+	data, err := json.Marshal(refund)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(refund.ID), data)
+}
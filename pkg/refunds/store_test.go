@@ -0,0 +1,152 @@
+package refunds
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"clawclack/pkg/money"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "data", "refunds.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreCreateAndGet(t *testing.T) {
+	store := openTestStore(t)
+
+	refund := &Refund{ID: "r1", OrderID: "o1", Amount: money.MustParseDecimal("2.5"), Currency: "USDT-TRC20", Network: "TRC20"}
+	if err := store.Create(refund); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Create(&Refund{ID: "r1"}); err == nil {
+		t.Error("Create with a duplicate ID succeeded")
+	}
+
+	got, err := store.Get("r1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.State != StateQueued || got.Money().String() != "2.5 USDT-TRC20" {
+		t.Errorf("Get = %s %s, want a queued refund of 2.5 USDT-TRC20", got.State, got.Money())
+	}
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unknown refund = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreTransition(t *testing.T) {
+	tests := []struct {
+		name  string
+		state State
+		next  State
+		from  []State
+		moved bool
+		want  State
+	}{
+		{"approve a queued refund", StateQueued, StateApproved, []State{StateQueued}, true, StateApproved},
+		{"send an approved refund", StateApproved, StateSending, []State{StateApproved, StateFailed}, true, StateSending},
+		{"retry a failed payout", StateFailed, StateSending, []State{StateApproved, StateFailed}, true, StateSending},
+		{"payout already started", StateSending, StateSending, []State{StateApproved, StateFailed}, false, StateSending},
+		{"reject after sending", StateSent, StateRejected, []State{StateQueued}, false, StateSent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openTestStore(t)
+			if err := store.Create(&Refund{ID: "r1", State: tt.state}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			called := false
+			refund, moved, err := store.Transition("r1", tt.next, func(r *Refund) {
+				called = true
+				r.ApprovedBy = "policy"
+			}, tt.from...)
+			if err != nil {
+				t.Fatalf("Transition failed: %v", err)
+			}
+			if moved != tt.moved || refund.State != tt.want {
+				t.Errorf("Transition = %s, %v, want %s, %v", refund.State, moved, tt.want, tt.moved)
+			}
+			if called != tt.moved {
+				t.Errorf("fn called = %v, want %v", called, tt.moved)
+			}
+
+			stored, _ := store.Get("r1")
+			if stored.State != tt.want || (stored.ApprovedBy == "policy") != tt.moved {
+				t.Errorf("stored %s approved by %q, want %s", stored.State, stored.ApprovedBy, tt.want)
+			}
+		})
+	}
+
+	store := openTestStore(t)
+	if _, _, err := store.Transition("missing", StateApproved, nil, StateQueued); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Transition unknown refund = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	store := openTestStore(t)
+	for id, state := range map[string]State{"r1": StateQueued, "r2": StateSent, "r3": StateQueued} {
+		if err := store.Create(&Refund{ID: id, State: state}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	queued, err := store.List(func(r *Refund) bool { return r.State == StateQueued })
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(queued) != 2 || queued[0].ID != "r1" || queued[1].ID != "r3" {
+		t.Errorf("queued refunds = %d, want r1 and r3", len(queued))
+	}
+	if all, _ := store.List(nil); len(all) != 3 {
+		t.Errorf("List(nil) returned %d refunds, want 3", len(all))
+	}
+}
+
+func TestStoreAddresses(t *testing.T) {
+	store := openTestStore(t)
+	alice, bob := "@alice:example.org", "@bob:example.org"
+
+	for _, a := range []struct{ user, network, address string }{
+		{alice, "TRC20", "TAlice"},
+		{alice, "btc", "bc1alice"},
+		{bob, "TRC20", "TBob"},
+	} {
+		if err := store.SetAddress(a.user, a.network, a.address); err != nil {
+			t.Fatalf("SetAddress failed: %v", err)
+		}
+	}
+
+	// Networks are matched without regard to case
+	if got, _ := store.Address(alice, "trc20"); got != "TAlice" {
+		t.Errorf("Address(alice, trc20) = %q, want TAlice", got)
+	}
+	if got, _ := store.Address(alice, "ERC20"); got != "" {
+		t.Errorf("Address(alice, ERC20) = %q, want none", got)
+	}
+
+	addresses, err := store.Addresses(alice)
+	if err != nil {
+		t.Fatalf("Addresses failed: %v", err)
+	}
+	if len(addresses) != 2 || addresses["trc20"] != "TAlice" || addresses["btc"] != "bc1alice" {
+		t.Errorf("Addresses(alice) = %v, want only her trc20 and btc addresses", addresses)
+	}
+
+	// A later registration replaces the address on that network
+	if err := store.SetAddress(alice, "TRC20", "TAlice2"); err != nil {
+		t.Fatalf("SetAddress failed: %v", err)
+	}
+	if got, _ := store.Address(alice, "TRC20"); got != "TAlice2" {
+		t.Errorf("Address after update = %q, want TAlice2", got)
+	}
+}
@@ -0,0 +1,94 @@
+package shkeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const defaultCatalogTTL = 10 * time.Minute
+
+// ErrUnsupportedCrypto is returned when SHKeeper has no wallet for a currency
+var ErrUnsupportedCrypto = errors.New("currency not supported")
+
+// Catalog caches the currencies SHKeeper has enabled so every command does
+// not have to ask it. A stale list is served if a refresh fails.
+type Catalog struct {
+	Client *Client
+	TTL    time.Duration
+
+	mu      sync.Mutex
+	cryptos []Crypto
+	fetched time.Time
+}
+
+// NewCatalog creates a catalog that refreshes every ttl
+func NewCatalog(client *Client, ttl time.Duration) *Catalog {
+	return &Catalog{Client: client, TTL: ttl}
+}
+
+// List returns the enabled currencies, refreshing the cache when it is stale
+func (c *Catalog) List(ctx context.Context) ([]Crypto, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = defaultCatalogTTL
+	}
+	if c.cryptos != nil && time.Since(c.fetched) < ttl {
+		return c.cryptos, nil
+	}
+
+	cryptos, err := c.Client.GetCryptos(ctx)
+	if err != nil {
+		if c.cryptos != nil {
+			log.Warn("Failed to refresh SHKeeper currencies, using cached list", "error", err)
+			return c.cryptos, nil
+		}
+		return nil, fmt.Errorf("failed to load SHKeeper currencies: %w", err)
+	}
+
+	c.cryptos = cryptos
+	c.fetched = time.Now()
+	return cryptos, nil
+}
+
+// Match returns the enabled currencies a user-supplied code refers to.
+// An exact code such as USDT-TRC20 matches only itself; a bare symbol
+// such as USDT matches it on every enabled network.
+func (c *Catalog) Match(ctx context.Context, code string) ([]Crypto, error) {
+	cryptos, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code = strings.ToUpper(code)
+	var matches []Crypto
+	for _, crypto := range cryptos {
+		if strings.ToUpper(crypto.Name) == code {
+			return []Crypto{crypto}, nil
+		}
+		if crypto.Symbol() == code {
+			matches = append(matches, crypto)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, ErrUnsupportedCrypto
+	}
+	return matches, nil
+}
+
+// Names lists the invoice codes of cryptos, e.g. "USDT-TRC20, USDC-POLYGON"
+func Names(cryptos []Crypto) string {
+	names := make([]string, len(cryptos))
+	for i, crypto := range cryptos {
+		names[i] = crypto.Name
+	}
+	return strings.Join(names, ", ")
+}
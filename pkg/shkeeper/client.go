@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
+
+	"clawclack/pkg/money"
 )
 
 // Client for SHKeeper API
@@ -18,10 +21,10 @@ type Client struct {
 
 // InvoiceRequest for creating payment invoices
 type InvoiceRequest struct {
-	OrderID   string  `json:"order_id"`
-	Amount    string  `json:"amount"`
-	Currency  string  `json:"currency"`
-	Callback  string  `json:"callback,omitempty"`
+	OrderID   string        `json:"order_id"`
+	Amount    money.Decimal `json:"amount"`
+	Currency  string        `json:"currency"`
+	Callback  string        `json:"callback,omitempty"`
 }
 
 // InvoiceResponse from SHKeeper
@@ -29,26 +32,62 @@ type InvoiceResponse struct {
 	OrderID     string `json:"order_id"`
 	PaymentURL  string `json:"payment_url"`
 	Address     string `json:"address"`
-	Amount      string `json:"amount"`
+	Amount      money.Decimal `json:"amount"`
 	Currency    string `json:"currency"`
 	Status      string `json:"status"`
 	ExpiresAt   time.Time `json:"expires_at"`
 }
 
+// Payment statuses reported by SHKeeper
+const (
+	StatusPending   = "pending"
+	StatusPartial   = "partial"   // some funds arrived, invoice still open
+	StatusConfirmed = "confirmed" // invoice closed with funds received
+	StatusExpired   = "expired"
+)
+
 // PaymentStatus for checking payments
 type PaymentStatus struct {
 	OrderID      string    `json:"order_id"`
-	Status       string    `json:"status"` // pending, confirmed, expired
-	Amount       string    `json:"amount"`
-	Currency     string    `json:"currency"`
-	Received     string    `json:"received"`
-	ConfirmedAt  time.Time `json:"confirmed_at,omitempty"`
+	Status       string        `json:"status"` // pending, partial, confirmed, expired
+	Amount       money.Decimal `json:"amount"`
+	Currency     string        `json:"currency"`
+	Received     money.Decimal `json:"received"`
+	ConfirmedAt  time.Time     `json:"confirmed_at,omitempty"`
+}
+
+// Invoiced returns the invoice amount with its currency
+func (s *PaymentStatus) Invoiced() money.Money {
+	return money.New(s.Amount, s.Currency)
+}
+
+// ReceivedAmount returns what actually arrived on-chain
+func (s *PaymentStatus) ReceivedAmount() money.Money {
+	return money.New(s.Received, s.Currency)
+}
+
+// Crypto is a currency SHKeeper has enabled, e.g. USDT-TRC20
+type Crypto struct {
+	Name        string `json:"name"`         // code used for invoices
+	DisplayName string `json:"display_name"` // e.g. "Tether TRC20"
+}
+
+// Symbol returns the coin without its network, e.g. USDT
+func (c Crypto) Symbol() string {
+	symbol, _, _ := strings.Cut(strings.ToUpper(c.Name), "-")
+	return symbol
+}
+
+// Network returns the network suffix, e.g. TRC20, or "" for native coins
+func (c Crypto) Network() string {
+	_, network, _ := strings.Cut(strings.ToUpper(c.Name), "-")
+	return network
 }
 
 // Balance represents wallet balance
 type Balance struct {
-	Currency string `json:"currency"`
-	Amount   string `json:"amount"`
+	Currency string        `json:"currency"`
+	Amount   money.Decimal `json:"amount"`
 }
 
 // New creates a new SHKeeper client
@@ -124,8 +163,46 @@ func (c *Client) CheckPayment(ctx context.Context, orderID string) (*PaymentStat
 	return &status, nil
 }
 
+// GetCryptos returns the currencies enabled on the SHKeeper instance
+func (c *Client) GetCryptos(ctx context.Context) ([]Crypto, error) {
+	url := fmt.Sprintf("%s/api/v1/crypto", c.BaseURL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("X-API-Key", c.APIKey)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("shkeeper returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Crypto     []string `json:"crypto"`
+		CryptoList []Crypto `json:"crypto_list"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	// Older SHKeeper versions only return the bare list of codes
+	if len(result.CryptoList) == 0 {
+		for _, name := range result.Crypto {
+			result.CryptoList = append(result.CryptoList, Crypto{Name: name, DisplayName: name})
+		}
+	}
+	return result.CryptoList, nil
+}
+
 // GetBalances returns all wallet balances
-func (c *Client) GetBalances(ctx context.Context) (map[string]string, error) {
+func (c *Client) GetBalances(ctx context.Context) (map[string]money.Decimal, error) {
 	url := fmt.Sprintf("%s/api/v1/balances", c.BaseURL)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -145,7 +222,7 @@ func (c *Client) GetBalances(ctx context.Context) (map[string]string, error) {
 		return nil, fmt.Errorf("shkeeper returned status %d", resp.StatusCode)
 	}
 
-	var balances map[string]string
+	var balances map[string]money.Decimal
 	if err := json.NewDecoder(resp.Body).Decode(&balances); err != nil {
 		return nil, err
 	}
@@ -153,14 +230,31 @@ func (c *Client) GetBalances(ctx context.Context) (map[string]string, error) {
 	return balances, nil
 }
 
-// SendPayment sends payment from SHKeeper wallet
-func (c *Client) SendPayment(ctx context.Context, currency, toAddress, amount string) error {
+// PayoutError is a payout request SHKeeper answered with an error status
+type PayoutError struct {
+	StatusCode int
+}
+
+func (e *PayoutError) Error() string {
+	return fmt.Sprintf("shkeeper returned status %d", e.StatusCode)
+}
+
+// Rejected reports whether SHKeeper refused the payout, so nothing was sent.
+// After server errors and conflicts the outcome is unknown.
+func (e *PayoutError) Rejected() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusConflict && e.StatusCode != http.StatusTooManyRequests
+}
+
+// SendPayment sends payment from SHKeeper wallet. Retrying with the same
+// idempotencyKey returns the first attempt's result instead of paying twice.
+func (c *Client) SendPayment(ctx context.Context, amount money.Money, toAddress, idempotencyKey string) error {
 	url := fmt.Sprintf("%s/api/v1/send", c.BaseURL)
 
 	payload := map[string]string{
-		"currency": currency,
+		"currency": amount.Currency,
 		"to":       toAddress,
-		"amount":   amount,
+		"amount":   amount.Amount.String(),
 	}
 
 	body, err := json.Marshal(payload)
@@ -175,6 +269,9 @@ func (c *Client) SendPayment(ctx context.Context, currency, toAddress, amount st
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-API-Key", c.APIKey)
+	if idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+	}
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
@@ -183,7 +280,7 @@ func (c *Client) SendPayment(ctx context.Context, currency, toAddress, amount st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("shkeeper returned status %d", resp.StatusCode)
+		return &PayoutError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
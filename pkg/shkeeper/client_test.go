@@ -0,0 +1,101 @@
+package shkeeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"clawclack/pkg/money"
+)
+
+// payoutServer pays each idempotency key once and replays the first result
+type payoutServer struct {
+	mu      sync.Mutex
+	status  int
+	results map[string]int
+	paid    []string
+}
+
+func (s *payoutServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/send" || r.Header.Get("X-API-Key") != "api-key" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var payload map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Header.Get("Idempotency-Key")
+	if status, ok := s.results[key]; ok && key != "" {
+		w.WriteHeader(status)
+		return
+	}
+	status := s.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status == http.StatusOK {
+		s.paid = append(s.paid, payload["amount"]+" "+payload["currency"]+" to "+payload["to"])
+	}
+	s.results[key] = status
+	w.WriteHeader(status)
+}
+
+func TestSendPaymentIdempotency(t *testing.T) {
+	server := &payoutServer{results: make(map[string]int)}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	c := New(srv.URL, "api-key")
+	amount := money.MustParse("1.5", "USDT-TRC20")
+
+	// A retry with the same key is answered without paying again
+	for i := 0; i < 3; i++ {
+		if err := c.SendPayment(context.Background(), amount, "TPayee", "spend-1"); err != nil {
+			t.Fatalf("SendPayment attempt %d failed: %v", i+1, err)
+		}
+	}
+	if err := c.SendPayment(context.Background(), amount, "TPayee", "spend-2"); err != nil {
+		t.Fatalf("SendPayment failed: %v", err)
+	}
+
+	if len(server.paid) != 2 || server.paid[0] != "1.5 USDT-TRC20 to TPayee" {
+		t.Errorf("paid %v, want 1.5 USDT-TRC20 to TPayee once per key", server.paid)
+	}
+}
+
+func TestSendPaymentErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(&payoutServer{status: tt.status, results: make(map[string]int)})
+		err := New(srv.URL, "api-key").SendPayment(context.Background(), money.MustParse("1", "USDT"), "TPayee", "spend-1")
+		srv.Close()
+
+		var payoutErr *PayoutError
+		if !errors.As(err, &payoutErr) {
+			t.Errorf("status %d: SendPayment = %v, want a PayoutError", tt.status, err)
+			continue
+		}
+		if payoutErr.StatusCode != tt.status || payoutErr.Rejected() != tt.rejected {
+			t.Errorf("status %d: PayoutError{%d}.Rejected() = %v, want %v",
+				tt.status, payoutErr.StatusCode, payoutErr.Rejected(), tt.rejected)
+		}
+	}
+}
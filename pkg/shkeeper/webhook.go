@@ -0,0 +1,91 @@
+package shkeeper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the callback body
+const SignatureHeader = "X-Shkeeper-Signature"
+
+// maxCallbackSize bounds how much of a callback body we are willing to read
+const maxCallbackSize = 64 << 10
+
+// ErrUnknownOrder can be returned by a callback handler for orders we never created
+var ErrUnknownOrder = errors.New("unknown order")
+
+// WebhookHandler receives payment callbacks from SHKeeper.
+// Every request must be signed with the shared webhook secret.
+type WebhookHandler struct {
+	Secret    string
+	OnPayment func(ctx context.Context, status *PaymentStatus) error
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxCallbackSize {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !VerifySignature(h.Secret, body, r.Header.Get(SignatureHeader)) {
+		log.Warn("Rejected SHKeeper callback with bad signature", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var status PaymentStatus
+	if err := json.Unmarshal(body, &status); err != nil || status.OrderID == "" {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	log.Info("📬 SHKeeper callback", "order", status.OrderID, "status", status.Status)
+
+	if err := h.OnPayment(r.Context(), &status); err != nil {
+		if errors.Is(err, ErrUnknownOrder) {
+			http.Error(w, "unknown order", http.StatusNotFound)
+			return
+		}
+		// Non-2xx makes SHKeeper retry the callback later
+		log.Error("Failed to process SHKeeper callback", "order", status.OrderID, "error", err)
+		http.Error(w, "processing failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// VerifySignature checks a hex encoded HMAC-SHA256 signature of body.
+// An empty secret never verifies, so a misconfigured bot fails closed.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
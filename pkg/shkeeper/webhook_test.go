@@ -0,0 +1,98 @@
+package shkeeper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSecret = "webhook-secret"
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := `{"order_id":"o1","status":"confirmed"}`
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", testSecret, body, sign(testSecret, body), true},
+		{"uppercase hex", testSecret, body, strings.ToUpper(sign(testSecret, body)), true},
+		{"empty secret", "", body, sign("", body), false},
+		{"missing signature", testSecret, body, "", false},
+		{"not hex", testSecret, body, "not-a-signature", false},
+		{"wrong secret", testSecret, body, sign("other-secret", body), false},
+		{"tampered body", testSecret, strings.Replace(body, "confirmed", "pending", 1), sign(testSecret, body), false},
+		{"truncated signature", testSecret, body, sign(testSecret, body)[:32], false},
+	}
+	for _, tt := range tests {
+		if got := VerifySignature(tt.secret, []byte(tt.body), tt.signature); got != tt.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	valid := `{"order_id":"o1","status":"confirmed"}`
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		signature string
+		result    error
+		want      int
+		delivered bool
+	}{
+		{"confirmed payment", http.MethodPost, valid, sign(testSecret, valid), nil, http.StatusOK, true},
+		{"wrong method", http.MethodGet, "", "", nil, http.StatusMethodNotAllowed, false},
+		{"bad signature", http.MethodPost, valid, sign("other-secret", valid), nil, http.StatusUnauthorized, false},
+		{"unsigned", http.MethodPost, valid, "", nil, http.StatusUnauthorized, false},
+		{"invalid JSON", http.MethodPost, "{", sign(testSecret, "{"), nil, http.StatusBadRequest, false},
+		{"missing order", http.MethodPost, `{"status":"confirmed"}`, sign(testSecret, `{"status":"confirmed"}`), nil, http.StatusBadRequest, false},
+		{"too large", http.MethodPost, strings.Repeat(" ", maxCallbackSize+1), "", nil, http.StatusRequestEntityTooLarge, false},
+		{"unknown order", http.MethodPost, valid, sign(testSecret, valid), ErrUnknownOrder, http.StatusNotFound, true},
+		{"processing failed", http.MethodPost, valid, sign(testSecret, valid), errors.New("store closed"), http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delivered *PaymentStatus
+			h := &WebhookHandler{
+				Secret: testSecret,
+				OnPayment: func(ctx context.Context, status *PaymentStatus) error {
+					delivered = status
+					return tt.result
+				},
+			}
+
+			req := httptest.NewRequest(tt.method, "/shkeeper/callback", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(SignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if (delivered != nil) != tt.delivered {
+				t.Fatalf("delivered = %v, want %v", delivered != nil, tt.delivered)
+			}
+			if delivered != nil && (delivered.OrderID != "o1" || delivered.Status != "confirmed") {
+				t.Errorf("delivered %s as %s, want o1 as confirmed", delivered.OrderID, delivered.Status)
+			}
+		})
+	}
+}
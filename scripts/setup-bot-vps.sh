@@ -38,6 +38,8 @@ ufw default allow outgoing
 ufw allow ssh
 ufw allow http
 ufw allow https
+# SHKeeper payment callbacks arrive over the VPN only
+ufw allow in on wg0 to any port 8080 proto tcp
 ufw --force enable
 
 # Install WireGuard (for secure SHKeeper connection)